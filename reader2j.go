@@ -4,13 +4,23 @@
 package x2j
 
 import (
+	"context"
 	"encoding/json"
-	"encoding/xml"
 	"io"
 )
 
 // ToTree() - parse a XML io.Reader to a tree of Nodes
 func ToTree(rdr io.Reader) (*Node, error) {
+	return defaultDecoder(nil).ToTree(rdr)
+}
+
+// (*Decoder)ToTree() - parse a XML io.Reader to a tree of Nodes using the Decoder settings.
+func (d *Decoder) ToTree(rdr io.Reader) (*Node, error) {
+	return d.toTree(context.Background(), rdr)
+}
+
+// (*Decoder)toTree() - ToTree, checking 'ctx' between tokens.
+func (d *Decoder) toTree(ctx context.Context, rdr io.Reader) (*Node, error) {
 	// We need to put an *os.File reader in a ByteReader or the xml.NewDecoder
 	// will wrap it in a bufio.Reader and seek on the file beyond where the
 	// xml.Decoder parses!
@@ -18,9 +28,9 @@ func ToTree(rdr io.Reader) (*Node, error) {
 		rdr = myByteReader(rdr) // see code at EOF
 	}
 
-	p := xml.NewDecoder(rdr)
-	p.CharsetReader = X2jCharsetReader
-	n, perr := xmlToTree("", nil, p)
+	p := d.newParser(rdr)
+	p.withContext(ctx)
+	n, perr := d.xmlToTree(p)
 	if perr != nil {
 		return nil, perr
 	}
@@ -30,52 +40,58 @@ func ToTree(rdr io.Reader) (*Node, error) {
 
 // ToMap() - parse a XML io.Reader to a map[string]interface{}
 func ToMap(rdr io.Reader, recast ...bool) (map[string]interface{}, error) {
-	var r bool
-	if len(recast) == 1 {
-		r = recast[0]
-	}
-	n, err := ToTree(rdr)
+	return defaultDecoder(recast).ToMap(rdr)
+}
+
+// (*Decoder)ToMap() - parse a XML io.Reader to a map[string]interface{} using the Decoder settings.
+func (d *Decoder) ToMap(rdr io.Reader) (map[string]interface{}, error) {
+	return d.toMap(context.Background(), rdr)
+}
+
+// (*Decoder)toMap() - ToMap, checking 'ctx' between tokens.
+func (d *Decoder) toMap(ctx context.Context, rdr io.Reader) (map[string]interface{}, error) {
+	n, err := d.toTree(ctx, rdr)
 	if err != nil {
 		return nil, err
 	}
 
-	m := make(map[string]interface{})
-	m[n.key] = n.treeToMap(r)
-
-	return m, nil
+	return n.ToMap(d), nil
 }
 
 // ToJson() - parse a XML io.Reader to a JSON string
 func ToJson(rdr io.Reader, recast ...bool) (string, error) {
-	var r bool
-	if len(recast) == 1 {
-		r = recast[0]
-	}
-	m, merr := ToMap(rdr, r)
-	if m == nil || merr != nil {
-		return "", merr
-	}
-
-	b, berr := json.Marshal(m)
-	if berr != nil {
-		return "", berr
-	}
+	return defaultDecoder(recast).ToJson(rdr)
+}
 
-	return string(b), nil
+// (*Decoder)ToJson() - parse a XML io.Reader to a JSON string using the Decoder settings.
+func (d *Decoder) ToJson(rdr io.Reader) (string, error) {
+	return d.toJson(context.Background(), rdr, false)
 }
 
 // ToJsonIndent - the pretty form of ReaderToJson
 func ToJsonIndent(rdr io.Reader, recast ...bool) (string, error) {
-	var r bool
-	if len(recast) == 1 {
-		r = recast[0]
-	}
-	m, merr := ToMap(rdr, r)
+	return defaultDecoder(recast).ToJsonIndent(rdr)
+}
+
+// (*Decoder)ToJsonIndent - the pretty form of (*Decoder)ToJson
+func (d *Decoder) ToJsonIndent(rdr io.Reader) (string, error) {
+	return d.toJson(context.Background(), rdr, true)
+}
+
+// (*Decoder)toJson - ToJson or, if 'indent', ToJsonIndent, checking 'ctx' between tokens.
+func (d *Decoder) toJson(ctx context.Context, rdr io.Reader, indent bool) (string, error) {
+	m, merr := d.toMap(ctx, rdr)
 	if m == nil || merr != nil {
 		return "", merr
 	}
 
-	b, berr := json.MarshalIndent(m, "", "  ")
+	var b []byte
+	var berr error
+	if indent {
+		b, berr = json.MarshalIndent(m, "", "  ")
+	} else {
+		b, berr = json.Marshal(m)
+	}
 	if berr != nil {
 		return "", berr
 	}
@@ -126,10 +142,13 @@ func (b *byteReader) Read(p []byte) (int, error) {
 }
 
 func (b *byteReader) ReadByte() (byte, error) {
-	_, err := b.r.Read(b.b)
-	if len(b.b) > 0 {
-		return b.b[0], nil
+	for {
+		n, err := b.r.Read(b.b)
+		if n == 1 {
+			return b.b[0], nil
+		}
+		if err != nil {
+			return 0, err
+		}
 	}
-	var c byte
-	return c, err
 }
@@ -42,6 +42,12 @@
        Demos: http://play.golang.org/p/kUQnZ8VuhS
    	        http://play.golang.org/p/l1aMHYtz7G
 
+   ValuesForKeySeq(), ValuesFromKeyPathSeq() and PathsForKeySeq() deliver the values as they're
+   found, for range-over-func loops that may break early.
+
+   For traversals of your own, Walk(m, fn) and (*Node).Walk(fn) visit each value of a map or
+   node of a tree with its path, including list indices, and can skip sub-trees or stop.
+
    NOTE: care should be taken when using "*" at the end of a path - i.e., "books.book.*".  See
    the x2jpath_test.go case on how the wildcard returns all key values and collapses list values;
    the same message structure can load a []interface{} or a map[string]interface{} (or an interface{}) 
@@ -56,16 +62,53 @@
       - If the element is a simple element and has attributes, the element value
         is given the key '#text' for its map[string]interface{} representation.  (See
         the 'atomFeedString.xml' test data, below.)
+      - Both can be changed with the Decoder AttrPrefix and TextKey settings - e.g., "@attr"
+        and "$t".  Use the Decoder's MapValue(), ValuesFromKeyPath(), etc., methods to query
+        maps decoded that way.
+      - Comments, processing instructions, directives and CDATA sections are discarded
+        unless they're kept with the Decoder Annotations setting.
+      - If an element has text interleaved with sub-elements, only one text segment is kept
+        unless the Decoder Mixed setting is MixedConcat or MixedSequence.
+      - Leading and trailing whitespace is trimmed from element values; the Decoder Whitespace
+        and XmlSpace settings can preserve or collapse it instead.
+      - An element that occurs once is decoded as a value, not a list of one value, unless it's
+        in the Decoder ForceList setting.
+      - Values are strings or, with 'recast', float64 or bool. The Decoder Types setting decodes
+        the values of particular elements and attributes as int64, uint64, time.Time, etc.,
+        and the Decoder Convert hook can decode any value based on its path.
 
     io.Reader HANDLING
 
     ToTree(), ToMap(), ToJson(), and ToJsonIndent() provide parsing of messages from an io.Reader.
     If you want to handle a message stream, look at XmlMsgsFromReader().
+    XmlMsgsFromFile() reads the file as it goes, so it can be larger than memory, and it can be
+    compressed with gzip or bzip2.
+    Messages can each have a prolog or a byte order mark, and stray text between them is skipped;
+    NewMsgSplitter() reads them one at a time, as raw bytes and decoded.
+    A malformed message is skipped - see BadMsgError and the Decoder MsgRoot setting.
+    XmlMsgsFromReaderWithMeta() and XmlMsgsFromFileWithMeta() report each message's sequence number
+    and input offsets, and XmlMsgsFromFileAt() resumes reading a file from a saved offset.
+    For XML from sources you don't trust, set the Decoder Limits - e.g., MaxDepth and MaxBytes.
+    XmlMsgsFromReaderSeq(), XmlMsgsFromFileSeq() and (*XmlBuffer).MapSeq() can be used in for-range loops.
+    The ...Context variants - ToMapContext(), XmlMsgsFromReaderContext(), etc. - can be canceled.
+
+    TREES
+
+    DocToTree(), ToTree(), etc., decode a doc to a tree of *Node, which keeps document order and
+    can be navigated and edited. (*Node).ToMap() and ToJson() return the same values as DocToMap()
+    and DocToJson() for the doc, and WriteXml() writes the tree as XML. With the Decoder Positions
+    setting, (*Node).Pos() is where a node starts in the doc, and maps have the positions too.
 
     NON-UTF8 CHARACTER SETS
 
     Use the X2jCharsetReader variable to assign io.Reader for alternative character sets.
 
+    PER-CALL SETTINGS
+
+    The 'recast' argument, X2jCharsetReader and CastNanInf() apply to the package-level functions.
+    To use different settings concurrently, configure a Decoder - d := NewDecoder() - and call its
+    methods - d.DocToMap(doc), d.ToMap(rdr), d.XmlMsgsFromReader(rdr, phandler, ehandler), etc.
+
 */
 // Deprecated: Use github.com/clbanning/mxj
 package x2j
@@ -77,7 +120,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"regexp"
 	"strconv"
 	"strings"
 )
@@ -89,22 +131,66 @@ import (
 //   s, err := x2j.DocToJson(doc)
 var X2jCharsetReader func(charset string, input io.Reader)(io.Reader, error)
 
+// Node - an element, attribute, text or annotation in a tree from DocToTree(), ToTree(), etc.
+// A tree keeps the doc in document order, so it can be walked as a simple DOM: the root element
+// is the Node returned; its Children() are its sub-elements, text and annotations, in order,
+// and its Attrs() are its attributes.
+//	n, _ := x2j.DocToTree(doc)
+//	for _, book := range n.ChildrenNamed("book") {
+//		id, _ := book.Attr("id")
+//		title := book.FirstChild("title").Value()
+//		...
+//	}
 type Node struct {
-	dup   bool   // is member of a list
-	attr  bool   // is an attribute
-	key   string // XML tag
-	val   string // element value
-	nodes []*Node
+	dup    bool   // is member of a list
+	attr   bool   // is an attribute
+	preserve bool // element: in the scope of xml:space="preserve"; see textSep()
+	key    string // XML tag
+	val    string // element value
+	nodes  []*Node
+	kind   NodeKind
+	prolog []*Node      // root only: annotations ahead of the root element
+	cv     interface{} // val decoded using a Decoder Types hint, if there is one
+	parent *Node
+	pfx    int      // AttrNode: the length of the AttrPrefix in key
+	forced bool     // is member of a list by the Decoder ForceList setting
+	pos    Position // where it starts in the doc
+	keys   *treeKeys // root only: the keys of new text and attribute nodes; see treeKeys()
+	name   xml.Name  // element or attribute: the name in the doc, with its prefix as Space
+}
+
+// NodeKind - what a Node holds. Annotation kinds only occur if the Decoder keeps them.
+type NodeKind int
+
+const (
+	ElementNode   NodeKind = iota
+	AttrNode               // key is the attribute name with the Decoder AttrPrefix
+	TextNode               // key is the Decoder TextKey
+	CommentNode            // key is CommentKey
+	ProcInstNode           // key is ProcInstKey
+	DirectiveNode          // key is DirectiveKey
+	CDATANode              // key is CDATAKey
+)
+
+// (*Node)Kind - what the node holds.
+func (n *Node) Kind() NodeKind {
+	return n.kind
+}
+
+// (*Node)isAnnotation - is the node a comment, processing instruction, directive or CDATA section?
+func (n *Node) isAnnotation() bool {
+	return n.kind >= CommentNode
 }
 
 // DocToJson - return an XML doc as a JSON string.
 //	If the optional argument 'recast' is 'true', then values will be converted to boolean or float64 if possible.
 func DocToJson(doc string, recast ...bool) (string, error) {
-	var r bool
-	if len(recast) == 1 {
-		r = recast[0]
-	}
-	m, merr := xmlToMap([]byte(doc), r)
+	return defaultDecoder(recast).DocToJson(doc)
+}
+
+// (*Decoder)DocToJson - return an XML doc as a JSON string using the Decoder settings.
+func (d *Decoder) DocToJson(doc string) (string, error) {
+	m, merr := d.xmlToMap([]byte(doc))
 	if m == nil || merr != nil {
 		return "", merr
 	}
@@ -122,11 +208,12 @@ func DocToJson(doc string, recast ...bool) (string, error) {
 //	If the optional argument 'recast' is 'true', then values will be converted to boolean or float64 if possible.
 //	Note: recasting is only applied to element values, not attribute values.
 func DocToJsonIndent(doc string, recast ...bool) (string, error) {
-	var r bool
-	if len(recast) == 1 {
-		r = recast[0]
-	}
-	m, merr := xmlToMap([]byte(doc), r)
+	return defaultDecoder(recast).DocToJsonIndent(doc)
+}
+
+// (*Decoder)DocToJsonIndent - return an XML doc as a prettified JSON string using the Decoder settings.
+func (d *Decoder) DocToJsonIndent(doc string) (string, error) {
+	m, merr := d.xmlToMap([]byte(doc))
 	if m == nil || merr != nil {
 		return "", merr
 	}
@@ -145,24 +232,24 @@ func DocToJsonIndent(doc string, recast ...bool) (string, error) {
 //	If the optional argument 'recast' is 'true', then values will be converted to boolean or float64 if possible.
 //	Note: recasting is only applied to element values, not attribute values.
 func DocToMap(doc string, recast ...bool) (map[string]interface{}, error) {
-	var r bool
-	if len(recast) == 1 {
-		r = recast[0]
-	}
-	return xmlToMap([]byte(doc), r)
+	return defaultDecoder(recast).DocToMap(doc)
+}
+
+// (*Decoder)DocToMap - convert an XML doc into a map[string]interface{} using the Decoder settings.
+func (d *Decoder) DocToMap(doc string) (map[string]interface{}, error) {
+	return d.xmlToMap([]byte(doc))
 }
 
 // DocToTree - convert an XML doc into a tree of nodes.
 func DocToTree(doc string) (*Node, error) {
-	// xml.Decoder doesn't properly handle whitespace in some doc
-	// see songTextString.xml test case ...
-	reg, _ := regexp.Compile("[ \t\n\r]*<")
-	doc = reg.ReplaceAllString(doc, "<")
+	return defaultDecoder(nil).DocToTree(doc)
+}
 
+// (*Decoder)DocToTree - convert an XML doc into a tree of nodes using the Decoder settings.
+func (d *Decoder) DocToTree(doc string) (*Node, error) {
 	b := bytes.NewBufferString(doc)
-	p := xml.NewDecoder(b)
-	p.CharsetReader = X2jCharsetReader
-	n, berr := xmlToTree("", nil, p)
+	p := d.newParser(b)
+	n, berr := d.xmlToTree(p)
 	if berr != nil {
 		return nil, berr
 	}
@@ -179,6 +266,9 @@ func (n *Node) WriteTree(padding ...int) string {
 	}
 
 	var s string
+	for _, nn := range n.prolog {
+		s += nn.WriteTree(indent)
+	}
 	if n.val != "" {
 		for i := 0; i < indent; i++ {
 			s += "  "
@@ -196,72 +286,148 @@ func (n *Node) WriteTree(padding ...int) string {
 	return s
 }
 
-// xmlToTree - load a 'clean' XML doc into a tree of *Node.
-func xmlToTree(skey string, a []xml.Attr, p *xml.Decoder) (*Node, error) {
-	n := new(Node)
-	n.nodes = make([]*Node, 0)
+// treeFrame - an element that's open in xmlToTree(); see mapFrame.
+type treeFrame struct {
+	n   *Node
+	ws  string   // whitespace-only value, for WhitespacePreserve
+	ns  *nsScope // the namespace scope of the element; see NamespaceMode
+	gap bool     // something other than text since the last of it; see joinText()
+}
 
-	if skey != "" {
-		n.key = skey
-		if len(a) > 0 {
-			for _, v := range a {
-				na := new(Node)
-				na.attr = true
-				na.key = `-` + v.Name.Local
-				na.val = v.Value
-				n.nodes = append(n.nodes, na)
-			}
-		}
+// (*Decoder)xmlToTree - load a 'clean' XML doc into a tree of *Node.
+func (d *Decoder) xmlToTree(p *xmlParser) (*Node, error) {
+	root := new(Node)
+	root.nodes = make([]*Node, 0)
+	if keys := (treeKeys{text: d.textKey(), attr: d.attrPrefix()}); keys != defaultKeys {
+		root.keys = &keys
 	}
+	var open []*treeFrame // the ancestors of 'f'
+	var f *treeFrame      // the current element; nil ahead of the root element
+
 	for {
 		t, err := p.Token()
 		if err != nil {
-			return nil, err
+			return nil, p.parseError(err)
 		}
 		switch t.(type) {
 		case xml.StartElement:
 			tt := t.(xml.StartElement)
-			// handle root
-			if n.key == "" {
-				n.key = tt.Name.Local
-				if len(tt.Attr) > 0 {
-					for _, v := range tt.Attr {
-						na := new(Node)
-						na.attr = true
-						na.key = `-` + v.Name.Local
-						na.val = v.Value
-						n.nodes = append(n.nodes, na)
-					}
-				}
-			} else {
-				nn, nnerr := xmlToTree(tt.Name.Local, tt.Attr, p)
-				if nnerr != nil {
-					return nil, nnerr
-				}
-				n.nodes = append(n.nodes, nn)
+			var ns *nsScope
+			if f != nil {
+				ns = f.ns
+				open = append(open, f)
+			}
+			// the scope is tracked for any NamespaceMode, for the names in the doc
+			nns := ns.push(tt.Attr)
+			n := root
+			if f != nil {
+				n = new(Node)
+				n.nodes = make([]*Node, 0)
+			}
+			n.key = d.nameKey(tt.Name, nns, false)
+			n.name = nns.docName(tt.Name, false)
+			n.preserve = p.preserve()
+			n.pos = p.pos
+			p.push(n.key)
+			var apos []Position
+			if len(tt.Attr) > 0 {
+				apos = p.attrPos(tt)
+			}
+			for i, v := range tt.Attr {
+				na := new(Node)
+				na.attr = true
+				na.kind = AttrNode
+				na.key = d.attrPrefix() + d.nameKey(v.Name, nns, true)
+				na.name = nns.docName(v.Name, true)
+				na.pfx = len(d.attrPrefix())
+				na.val = v.Value
+				na.parent = n
+				na.pos = apos[i]
+				n.nodes = append(n.nodes, na)
 			}
+			f = &treeFrame{n: n, ns: nns}
 		case xml.EndElement:
-			// scan n.nodes for duplicate n.key values
-			n.markDuplicateKeys()
-			return n, nil
+			nn := f.n
+			if len(nn.nodes) == 0 && nn.val == "" {
+				nn.val = f.ws
+			}
+			if err := nn.convert(d, p); err != nil {
+				return nil, p.parseError(err)
+			}
+			// scan nn.nodes for duplicate nn.key values
+			nn.markDuplicateKeys()
+			p.pop()
+			if len(open) == 0 {
+				return nn, nil
+			}
+			f, open = open[len(open)-1], open[:len(open)-1]
+			if d.Mixed != MixedDefault {
+				// keep the text ahead of it in order
+				f.n.textToNode(d)
+			}
+			if p.forced(nn.key) {
+				nn.dup, nn.forced = true, true
+			}
+			nn.parent = f.n
+			f.n.nodes = append(f.n.nodes, nn)
+			f.gap = true
 		case xml.CharData:
-			tt := string(t.(xml.CharData))
+			if f == nil {
+				// stray text ahead of the root element
+				continue
+			}
+			n := f.n
+			if key, val, kind, ok := d.annotation(t, p); ok {
+				n.addAnnotation(d, key, val, kind, p.pos)
+				f.gap = true
+				continue
+			}
 			// 28-jan-14 ... clean up noise input
-			tt = strings.Trim(tt,"\t\r\b\n ")
-			if len(n.nodes) > 0 && len(tt) > 0 {
+			tt, ok := d.text(string(t.(xml.CharData)), p.preserve())
+			if !ok {
+				f.ws = tt
+				continue
+			}
+			if len(n.nodes) == 0 && n.val != "" && d.Mixed == MixedDefault {
+				// another text segment - e.g., a CDATA section; it's kept for WriteXml(),
+				// though for maps it overwrites the value - see leadText()
+				n.textToNode(d)
+			}
+			var last *Node // the text 'tt' is joined to
+			if l := len(n.nodes); l > 0 && n.nodes[l-1].kind == TextNode {
+				last = n.nodes[l-1]
+			}
+			switch {
+			case d.Mixed != MixedDefault && len(n.nodes) == 0:
+				n.val = d.joinText(n.val, tt, f.gap, n.preserve)
+			case d.Mixed != MixedDefault && last != nil:
+				// text segments only separated by discarded comments, etc.
+				last.val = d.joinText(last.val, tt, f.gap, n.preserve)
+			case len(n.nodes) > 0:
 				nn := new(Node)
-				nn.key = "#text"
+				nn.key = d.textKey()
+				nn.kind = TextNode
 				nn.val = tt
+				nn.parent = n
 				n.nodes = append(n.nodes, nn)
-			} else {
+			default:
 				n.val = tt
 			}
+			f.gap = false
 		default:
-			// noop
+			if key, val, kind, ok := d.annotation(t, p); ok {
+				if f == nil {
+					// ahead of the root element
+					root.prolog = append(root.prolog, &Node{key: key, val: val, kind: kind, pos: p.pos})
+				} else {
+					f.n.addAnnotation(d, key, val, kind, p.pos)
+				}
+			}
+			if f != nil {
+				f.gap = true
+			}
 		}
 	}
-	// Logically we can't get here, but provide an error message anyway.
-	return nil, errors.New("Unknown parse error in xmlToTree() for: " + n.key)
 }
 
 // (*Node)markDuplicateKeys - set node.dup flag for loading map[string]interface{}.
@@ -280,55 +446,129 @@ func (n *Node) markDuplicateKeys() {
 	}
 }
 
+// (*Node)ToMap - convert a tree of nodes into a map[string]interface{}; for the root element,
+// it's the map DocToMap() returns for the doc, including its prolog.
+//	'd' is the Decoder the tree was decoded with - its Recast, CastNanInf, Mixed, TextKey and
+//	Positions settings apply, as for DocToMap(); if it's nil, the package-level defaults are used.
+func (n *Node) ToMap(d *Decoder) map[string]interface{} {
+	if d == nil {
+		d = defaultDecoder(nil)
+	}
+	m := make(map[string]interface{})
+	m[n.key] = n.treeToMap(d)
+	n.prologToMap(m)
+	if d.Positions {
+		m[PosKey] = n.positions()
+	}
+	return m
+}
+
+// (*Node)ToJson - convert a tree of nodes into a JSON string; see ToMap().
+func (n *Node) ToJson(d *Decoder) (string, error) {
+	j, err := json.Marshal(n.ToMap(d))
+	return string(j), err
+}
+
 // (*Node)treeToMap - convert a tree of nodes into a map[string]interface{}.
 //	(Parses to map that is structurally the same as from json.Unmarshal().)
-// Note: root is not instantiated; call with: "m[n.key] = n.treeToMap(recast)".
-func (n *Node) treeToMap(r bool) interface{} {
-	if len(n.nodes) == 0 {
-		return recast(n.val, r)
+// The values are cast and grouped into lists as they are by elementToMap().
+// Note: root is not instantiated; call n.ToMap().
+func (n *Node) treeToMap(d *Decoder) interface{} {
+	if !n.hasMapNodes(d) {
+		return n.value(d)
 	}
-
-	m := make(map[string]interface{}, 0)
-	for _, v := range n.nodes {
-		// just a value
-		if !v.dup && len(v.nodes) == 0 {
-			m[v.key] = recast(v.val, r)
-			continue
-		}
-
-		// a list of values
-		if v.dup {
-			var a []interface{}
-			if vv, ok := m[v.key]; ok {
-				a = vv.([]interface{})
+	type frame struct {
+		n    *Node
+		vals []interface{} // the values of the sub-nodes converted so far
+	}
+	newFrame := func(n *Node) frame {
+		return frame{n: n, vals: make([]interface{}, 0, len(n.nodes))}
+	}
+	open := []frame{newFrame(n)}
+	for {
+		f := &open[len(open)-1]
+		if i := len(f.vals); i < len(f.n.nodes) {
+			if v := f.n.nodes[i]; v.kind == ElementNode && v.hasMapNodes(d) {
+				open = append(open, newFrame(v))
 			} else {
-				a = make([]interface{}, 0)
+				f.vals = append(f.vals, v.value(d))
 			}
-			a = append(a, v.treeToMap(r))
-			m[v.key] = interface{}(a)
 			continue
 		}
+		val := f.n.nodesToMap(d, f.vals)
+		if open = open[:len(open)-1]; len(open) == 0 {
+			return val
+		}
+		f = &open[len(open)-1]
+		f.vals = append(f.vals, val)
+	}
+}
+
+// (*Node)hasMapNodes - is the map value of 'n' built from its sub-nodes? If not, it's n.value().
+func (n *Node) hasMapNodes(d *Decoder) bool {
+	// text ahead of the attributes and sub-elements is the value; see MixedDefault
+	return len(n.nodes) > 0 && (d.Mixed != MixedDefault || n.val == "" && n.leadText() == nil)
+}
 
-		// it's a unique key
-		m[v.key] = v.treeToMap(r)
+// (*Node)leadText - for MixedDefault, the last of the TextNodes 'n' starts with, which is its
+// value: as for DocToMap(), each text segment ahead of the sub-elements overwrites the one
+// before it. It's nil if there are none or 'n' has annotations, which make the text a TextKey
+// value.
+func (n *Node) leadText() *Node {
+	var t *Node
+	lead := true
+	for _, v := range n.nodes {
+		if v.isAnnotation() {
+			return nil
+		}
+		if lead = lead && v.kind == TextNode; lead {
+			t = v
+		}
 	}
+	return t
+}
 
-	return interface{}(m)
+// (*Node)nodesToMap - the map value of 'n', given the map values 'vals' of its sub-nodes.
+func (n *Node) nodesToMap(d *Decoder, vals []interface{}) interface{} {
+	if d.Mixed != MixedDefault {
+		if v, ok := n.mixedToMap(d, vals); ok {
+			return v
+		}
+	}
+	return nodesMap(n.nodes, vals)
 }
 
-// recast - try to cast string values to bool or float64
-func recast(s string, r bool) interface{} {
-	if r {
-		// handle numeric strings ahead of boolean
-		if f, err := strconv.ParseFloat(s, 64); err == nil {
-			return interface{}(f)
+// nodesMap - the map of 'nodes', given their map values 'vals'.
+func nodesMap(nodes []*Node, vals []interface{}) map[string]interface{} {
+	m := make(map[string]interface{}, 0)
+	for i, v := range nodes {
+		switch v.kind {
+		case ElementNode:
+			addListValue(m, v.key, vals[i], v.forced)
+		case AttrNode, TextNode:
+			// text segments overwrite one another
+			m[v.key] = vals[i]
+		default:
+			addMapValue(m, v.key, vals[i])
 		}
-		// ParseBool treats "1"==true & "0"==false
-		if b, err := strconv.ParseBool(s); err == nil {
-			return interface{}(b)
+	}
+	return m
+}
+
+// (*Node)value - the map value of a node without sub-nodes.
+func (n *Node) value(d *Decoder) interface{} {
+	if n.cv != nil {
+		return n.cv
+	}
+	if n.isAnnotation() {
+		return n.val
+	}
+	if d.Mixed == MixedDefault && n.val == "" {
+		if t := n.leadText(); t != nil {
+			return t.value(d)
 		}
 	}
-	return interface{}(s)
+	return d.cast(n.val)
 }
 
 // WriteMap - dumps the map[string]interface{} for examination.
@@ -342,49 +582,73 @@ func WriteMap(m interface{}, offset ...int) string {
 		indent = offset[0]
 	}
 
-	var s string
-	switch m.(type) {
-	case nil:
-		return "[nil] nil"
-	case string:
-		return "[string] " + m.(string)
-	case float64:
-		return "[float64] " + strconv.FormatFloat(m.(float64), 'e', 2, 64)
-	case bool:
-		return "[bool] " + strconv.FormatBool(m.(bool))
-	case []interface{}:
-		s += "[[]interface{}]"
-		for i, v := range m.([]interface{}) {
-			s += "\n"
-			for i := 0; i < indent; i++ {
-				s += "  "
+	var s strings.Builder
+	var open []*writeMapFrame // the maps and lists being written
+	for {
+		switch m.(type) {
+		case nil:
+			s.WriteString("[nil] nil")
+		case string:
+			s.WriteString("[string] " + m.(string))
+		case float64:
+			s.WriteString("[float64] " + strconv.FormatFloat(m.(float64), 'e', 2, 64))
+		case bool:
+			s.WriteString("[bool] " + strconv.FormatBool(m.(bool)))
+		case []interface{}:
+			s.WriteString("[[]interface{}]")
+			open = append(open, &writeMapFrame{a: m.([]interface{}), indent: indent})
+		case map[string]interface{}:
+			f := &writeMapFrame{m: m.(map[string]interface{}), indent: indent}
+			for k := range f.m {
+				f.keys = append(f.keys, k)
 			}
-			s += "[item: " + strconv.FormatInt(int64(i), 10) + "]"
-			switch v.(type) {
+			open = append(open, f)
+		default:
+			// shouldn't ever be here ...
+			fmt.Fprintf(&s, "unknown type for: %v", m)
+		}
+
+		// the next value to write
+		for len(open) > 0 && open[len(open)-1].done() {
+			open = open[:len(open)-1]
+		}
+		if len(open) == 0 {
+			return s.String()
+		}
+		f := open[len(open)-1]
+		pad := strings.Repeat("  ", f.indent)
+		if f.a != nil {
+			m = f.a[f.next]
+			s.WriteString("\n" + pad + "[item: " + strconv.FormatInt(int64(f.next), 10) + "]")
+			switch m.(type) {
 			case string, float64, bool:
-				s += "\n"
+				s.WriteString("\n")
 			default:
 				// noop
 			}
-			for i := 0; i < indent; i++ {
-				s += "  "
-			}
-			s += WriteMap(v, indent+1)
-		}
-	case map[string]interface{}:
-		for k, v := range m.(map[string]interface{}) {
-			s += "\n"
-			for i := 0; i < indent; i++ {
-				s += "  "
-			}
+			s.WriteString(pad)
+		} else {
+			k := f.keys[f.next]
+			m = f.m[k]
 			// s += "[map[string]interface{}] "+k+" :"+WriteMap(v,indent+1)
-			s += k + " :" + WriteMap(v, indent+1)
+			s.WriteString("\n" + pad + k + " :")
 		}
-	default:
-		// shouldn't ever be here ...
-		s += fmt.Sprintf("unknown type for: %v", m)
+		f.next++
+		indent = f.indent + 1
 	}
-	return s
+}
+
+// writeMapFrame - a map or list value that WriteMap() is writing.
+type writeMapFrame struct {
+	m      map[string]interface{}
+	keys   []string // of 'm'
+	a      []interface{}
+	next   int // the next sub-value to write
+	indent int // of the sub-values
+}
+
+func (f *writeMapFrame) done() bool {
+	return f.next == len(f.a)+len(f.keys)
 }
 
 // ------------------------  value extraction from XML doc --------------------------
@@ -395,16 +659,21 @@ func WriteMap(m interface{}, offset ...int) string {
 //	'attrs' is an OPTIONAL list of "name:value" pairs for attributes.
 //	Note: 'recast' is not enabled here. Use DocToMap(), NewAttributeMap(), and MapValue() calls for that.
 func DocValue(doc, path string, attrs ...string) (interface{}, error) {
-	m, err := xmlToMap([]byte(doc), false)
+	return defaultDecoder(nil).DocValue(doc, path, attrs...)
+}
+
+// (*Decoder)DocValue - DocValue using the Decoder settings.
+func (d *Decoder) DocValue(doc, path string, attrs ...string) (interface{}, error) {
+	m, err := d.xmlToMap([]byte(doc))
 	if err != nil {
 		return nil, err
 	}
 
-	a, err := NewAttributeMap(attrs...)
+	a, err := d.NewAttributeMap(attrs...)
 	if err != nil {
 		return nil, err
 	}
-	v, verr := MapValue(m, path, a)
+	v, verr := d.MapValue(m, path, a)
 	if verr != nil {
 		return nil, verr
 	}
@@ -414,25 +683,32 @@ func DocValue(doc, path string, attrs ...string) (interface{}, error) {
 // MapValue - retrieves value based on walking the map, 'm'.
 //	'm' is the map value of interest.
 //	'path' is a period-separated hierarchy of keys in the map.
+//	       Namespaced keys match in any NamespaceMode form - "Body", "s:Body" or "{uri}Body".
 //	'attr' is a map of attribute "name:value" pairs from NewAttributeMap().  May be 'nil'.
 //	If the path can't be traversed, an error is returned.
 //	Note: the optional argument 'r' can be used to coerce attribute values, 'attr', if done so for 'm'.
 func MapValue(m map[string]interface{}, path string, attr map[string]interface{}, r ...bool) (interface{}, error) {
+	return defaultDecoder(nil).MapValue(m, path, attr, r...)
+}
+
+// (*Decoder)MapValue - MapValue for a map decoded using the Decoder settings.
+//	'attr' is from d.NewAttributeMap(); the value returned for matching attributes is the d.TextKey value.
+func (d *Decoder) MapValue(m map[string]interface{}, path string, attr map[string]interface{}, r ...bool) (interface{}, error) {
 	// attribute values may have been recasted during map construction; default is 'false'.
 	if len(r) == 1 && r[0] == true {
 		for k, v := range attr {
-			attr[k] = recast(v.(string), true)
+			attr[k] = cast(v.(string), d.CastNanInf)
 		}
 	}
 
 	// parse the path
-	keys := strings.Split(path, ".")
+	keys := splitPath(path)
 
 	// initialize return value to 'm' so a path of "" will work correctly
 	var v interface{} = m
-	var ok bool
 	var okey string
 	var isMap bool = true
+	var scope []interface{} // enclosing map values, for resolving namespace prefixes
 	if keys[0] == "" && len(attr) == 0 {
 		return v, nil
 	}
@@ -440,9 +716,12 @@ func MapValue(m map[string]interface{}, path string, attr map[string]interface{}
 		if !isMap {
 			return nil, errors.New("no keys beyond: " + okey)
 		}
-		if v, ok = m[key]; !ok {
+		// matches the key in any NamespaceMode form
+		if vals := d.valuesForPathKey(m, key, scope); len(vals) == 0 {
 			return nil, errors.New("no key in map: " + key)
 		} else {
+			scope = append(scope, m)
+			v = vals[0]
 			switch v.(type) {
 			case map[string]interface{}:
 				m = v.(map[string]interface{})
@@ -455,20 +734,20 @@ func MapValue(m map[string]interface{}, path string, attr map[string]interface{}
 		okey = key
 	}
 
-	// match attributes; value is "#text" or nil
+	// match attributes; value is d.TextKey or nil
 	if attr == nil {
 		return v, nil
 	}
-	return hasAttributes(v, attr)
+	return d.hasAttributes(v, attr)
 }
 
-// hasAttributes() - interface{} equality works for string, float64, bool
-func hasAttributes(v interface{}, a map[string]interface{}) (interface{}, error) {
+// (*Decoder)hasAttributes() - interface{} equality works for string, float64, bool
+func (d *Decoder) hasAttributes(v interface{}, a map[string]interface{}) (interface{}, error) {
 	switch v.(type) {
 	case []interface{}:
 		// run through all entries looking one with matching attributes
 		for _, vv := range v.([]interface{}) {
-			if vvv, vvverr := hasAttributes(vv, a); vvverr == nil {
+			if vvv, vvverr := d.hasAttributes(vv, a); vvverr == nil {
 				return vvv, nil
 			}
 		}
@@ -478,13 +757,13 @@ func hasAttributes(v interface{}, a map[string]interface{}) (interface{}, error)
 		nv := v.(map[string]interface{})
 		for key, val := range a {
 			if vv, ok := nv[key]; !ok {
-				return nil, errors.New("no attribute with name: " + key[1:])
+				return nil, errors.New("no attribute with name: " + key[len(d.attrPrefix()):])
 			} else if val != vv {
-				return nil, errors.New("no attribute key:value pair: " + fmt.Sprintf("%s:%v", key[1:], val))
+				return nil, errors.New("no attribute key:value pair: " + fmt.Sprintf("%s:%v", key[len(d.attrPrefix()):], val))
 			}
 		}
-		// they all match; so return value associated with "#text" key.
-		if vv, ok := nv["#text"]; ok {
+		// they all match; so return value associated with d.TextKey key.
+		if vv, ok := nv[d.textKey()]; ok {
 			return vv, nil
 		} else {
 			// this happens when another element is value of tag rather than just a string value
@@ -498,6 +777,11 @@ func hasAttributes(v interface{}, a map[string]interface{}) (interface{}, error)
 //	'kv' arguments are "name:value" pairs that appear as attributes, name="value".
 //	If len(kv) == 0, the return is (nil, nil).
 func NewAttributeMap(kv ...string) (map[string]interface{}, error) {
+	return defaultDecoder(nil).NewAttributeMap(kv...)
+}
+
+// (*Decoder)NewAttributeMap() - generate map of attributes=value entries as map[d.AttrPrefix+string]string.
+func (d *Decoder) NewAttributeMap(kv ...string) (map[string]interface{}, error) {
 	if len(kv) == 0 {
 		return nil, nil
 	}
@@ -507,8 +791,8 @@ func NewAttributeMap(kv ...string) (map[string]interface{}, error) {
 		if len(vv) != 2 {
 			return nil, errors.New("attribute not \"name:value\" pair: " + v)
 		}
-		// attributes are stored as keys prepended with hyphen
-		m["-"+vv[0]] = interface{}(vv[1])
+		// attributes are stored as keys prepended with d.AttrPrefix
+		m[d.attrPrefix()+vv[0]] = interface{}(vv[1])
 	}
 	return m, nil
 }
@@ -533,29 +817,29 @@ func ValuesForTag(doc, tag string) ([]interface{}, error) {
 func ValuesForKey(m map[string]interface{}, key string) []interface{} {
 	ret := make([]interface{}, 0)
 
-	hasKey(m, key, &ret)
+	hasKey(m, key, appendTo(&ret))
 	if len(ret) > 0 {
 		return ret
 	}
 	return nil
 }
 
-// hasKey - if the map 'key' exists append it to array
-//          if it doesn't do nothing except scan array and map values
-func hasKey(iv interface{}, key string, ret *[]interface{}) {
-	switch iv.(type) {
-	case map[string]interface{}:
-		vv := iv.(map[string]interface{})
-		if v, ok := vv[key]; ok {
-			*ret = append(*ret, v)
-		}
-		for _, v := range iv.(map[string]interface{}) {
-			hasKey(v, key, ret)
-		}
-	case []interface{}:
-		for _, v := range iv.([]interface{}) {
-			hasKey(v, key, ret)
+// hasKey - pass the value of each map 'key' in 'm' to 'yield' - a list value as a whole -
+//          until 'yield' returns false
+func hasKey(m map[string]interface{}, key string, yield func(interface{}) bool) {
+	walkValues(m, func(at *crumb, k string, v interface{}) WalkAction {
+		if k == key && !yield(v) {
+			return WalkStop
 		}
+		return WalkContinue
+	})
+}
+
+// appendTo - a 'yield' function for hasKey(), etc., that appends the values to 'ret'.
+func appendTo(ret *[]interface{}) func(interface{}) bool {
+	return func(v interface{}) bool {
+		*ret = append(*ret, v)
+		return true
 	}
 }
 
@@ -568,22 +852,26 @@ func hasKey(iv interface{}, key string, ret *[]interface{}) {
 //	       x2j.Unmarshal(doc,&struct) - passed to xml.Unmarshal()
 //	       x2j.Unmarshal(doc,&slice) - passed to xml.Unmarshal()
 func Unmarshal(doc []byte, v interface{}) error {
+	return defaultDecoder(nil).Unmarshal(doc, v)
+}
+
+// (*Decoder)Unmarshal - Unmarshal using the Decoder settings.
+func (d *Decoder) Unmarshal(doc []byte, v interface{}) error {
 	switch v.(type) {
 	case *map[string]interface{}:
-		m, err := ByteDocToMap(doc)
+		m, err := d.ByteDocToMap(doc)
 		vv := *v.(*map[string]interface{})
 		for k, v := range m {
 			vv[k] = v
 		}
 		return err
 	case *string:
-		s, err := ByteDocToJson(doc)
+		s, err := d.ByteDocToJson(doc)
 		*(v.(*string)) = s
 		return err
 	default:
 		b := bytes.NewBuffer(doc)
-		p := xml.NewDecoder(b)
-		p.CharsetReader = X2jCharsetReader
+		p := d.newXmlDecoder(b)
 		return p.Decode(v)
 		// return xml.Unmarshal(doc, v)
 	}
@@ -593,11 +881,12 @@ func Unmarshal(doc []byte, v interface{}) error {
 // ByteDocToJson - return an XML doc as a JSON string.
 //	If the optional argument 'recast' is 'true', then values will be converted to boolean or float64 if possible.
 func ByteDocToJson(doc []byte, recast ...bool) (string, error) {
-	var r bool
-	if len(recast) == 1 {
-		r = recast[0]
-	}
-	m, merr := ByteDocToMap(doc, r)
+	return defaultDecoder(recast).ByteDocToJson(doc)
+}
+
+// (*Decoder)ByteDocToJson - return an XML doc as a JSON string using the Decoder settings.
+func (d *Decoder) ByteDocToJson(doc []byte) (string, error) {
+	m, merr := d.ByteDocToMap(doc)
 	if m == nil || merr != nil {
 		return "", merr
 	}
@@ -616,28 +905,27 @@ func ByteDocToJson(doc []byte, recast ...bool) (string, error) {
 //	If the optional argument 'recast' is 'true', then values will be converted to boolean or float64 if possible.
 //	Note: recasting is only applied to element values, not attribute values.
 func ByteDocToMap(doc []byte, recast ...bool) (map[string]interface{}, error) {
-	var r bool
-	if len(recast) == 1 {
-		r = recast[0]
-	}
-	return xmlToMap(doc, r)
+	return defaultDecoder(recast).ByteDocToMap(doc)
+}
+
+// (*Decoder)ByteDocToMap - convert an XML doc into a map[string]interface{} using the Decoder settings.
+func (d *Decoder) ByteDocToMap(doc []byte) (map[string]interface{}, error) {
+	return d.xmlToMap(doc)
 }
 
 // ByteDocToTree - convert an XML doc into a tree of nodes.
 func ByteDocToTree(doc []byte) (*Node, error) {
-	// xml.Decoder doesn't properly handle whitespace in some doc
-	// see songTextString.xml test case ...
-	reg, _ := regexp.Compile("[ \t\n\r]*<")
-	doc = reg.ReplaceAll(doc, []byte("<"))
+	return defaultDecoder(nil).ByteDocToTree(doc)
+}
 
+// (*Decoder)ByteDocToTree - convert an XML doc into a tree of nodes using the Decoder settings.
+func (d *Decoder) ByteDocToTree(doc []byte) (*Node, error) {
 	b := bytes.NewBuffer(doc)
-	p := xml.NewDecoder(b)
-	p.CharsetReader = X2jCharsetReader
-	n, berr := xmlToTree("", nil, p)
+	p := d.newParser(b)
+	n, berr := d.xmlToTree(p)
 	if berr != nil {
 		return nil, berr
 	}
 
 	return n, nil
 }
-
@@ -0,0 +1,94 @@
+// Copyright 2012-2016 Charles Banning. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+//	x2j_annotations.go: keep comments, processing instructions, directives and CDATA sections.
+
+package x2j
+
+import "encoding/xml"
+
+// Annotation - the XML tokens, other than elements, attributes and text, that a Decoder keeps.
+// The values can be OR'd together; e.g., d.Annotations = AnnotateComments | AnnotateProcInst.
+//
+// Annotations are decoded as values of the reserved keys CommentKey, ProcInstKey, DirectiveKey
+// and CDATAKey in the map of the enclosing element - as lists if there's more than one - and
+// as Node values of the corresponding NodeKind. Those that come ahead of the root element, like
+// <?xml-stylesheet ...?>, are keys of the top level map along with the root element's key.
+// The <?xml ...?> declaration is not kept and annotation values are neither trimmed nor recast.
+type Annotation int
+
+const (
+	// AnnotateComments - keep <!--comment--> as "#comment": "comment".
+	AnnotateComments Annotation = 1 << iota
+	// AnnotateProcInst - keep <?target inst?> as "#pi": "target inst".
+	AnnotateProcInst
+	// AnnotateDirectives - keep <!DOCTYPE doc> as "#directive": "DOCTYPE doc".
+	AnnotateDirectives
+	// AnnotateCDATA - keep <![CDATA[text]]> as "#cdata": "text" rather than as element text.
+	// Only CDATA sections in UTF-8 docs and streams are recognized.
+	AnnotateCDATA
+	// AnnotateAll - keep all of the above.
+	AnnotateAll = AnnotateComments | AnnotateProcInst | AnnotateDirectives | AnnotateCDATA
+)
+
+// The reserved keys for annotations.
+const (
+	CommentKey   = "#comment"
+	ProcInstKey  = "#pi"
+	DirectiveKey = "#directive"
+	CDATAKey     = "#cdata"
+)
+
+// (*Decoder)annotation - the key, value and NodeKind for token 't' if it's an annotation the Decoder keeps.
+// 't' is the last token from 'p'.
+func (d *Decoder) annotation(t xml.Token, p *xmlParser) (string, string, NodeKind, bool) {
+	switch t.(type) {
+	case xml.Comment:
+		if d.Annotations&AnnotateComments != 0 {
+			return CommentKey, string(t.(xml.Comment)), CommentNode, true
+		}
+	case xml.ProcInst:
+		tt := t.(xml.ProcInst)
+		if d.Annotations&AnnotateProcInst != 0 && tt.Target != "xml" {
+			s := tt.Target
+			if len(tt.Inst) > 0 {
+				s += " " + string(tt.Inst)
+			}
+			return ProcInstKey, s, ProcInstNode, true
+		}
+	case xml.Directive:
+		if d.Annotations&AnnotateDirectives != 0 {
+			return DirectiveKey, string(t.(xml.Directive)), DirectiveNode, true
+		}
+	case xml.CharData:
+		if p.cdata {
+			return CDATAKey, string(t.(xml.CharData)), CDATANode, true
+		}
+	}
+	return "", "", 0, false
+}
+
+// (*Decoder)addAnnotation - add an annotation to 'na', the map value of element 'skey'.
+// An element value that's already been parsed into 'n' is moved to the d.TextKey key.
+func (d *Decoder) addAnnotation(n, na map[string]interface{}, skey, key, val string) {
+	if v, ok := n[skey]; ok {
+		na[d.textKey()] = v
+		delete(n, skey)
+	}
+	addMapValue(na, key, val)
+}
+
+// (*Node)addAnnotation - append an annotation, at 'pos', to the sub-nodes of 'n'.
+// An element value that's already been parsed is kept as a TextNode ahead of it.
+func (n *Node) addAnnotation(d *Decoder, key, val string, kind NodeKind, pos Position) {
+	n.textToNode(d)
+	n.nodes = append(n.nodes, &Node{key: key, val: val, kind: kind, parent: n, pos: pos})
+}
+
+// (*Node)prologToMap - add the annotations ahead of the root element to the top level map.
+func (n *Node) prologToMap(m map[string]interface{}) {
+	for _, v := range n.prolog {
+		addMapValue(m, v.key, v.val)
+	}
+}
@@ -9,85 +9,59 @@ package x2j
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
-	"os"
-	"regexp"
 )
 
 // XmlMsgsFromFileAsJson()
-//	'fname' is name of file
+//	'fname' is name of file; if it's compressed with gzip or bzip2, it's decompressed as it's read
 //	'phandler' is the JSON string processing handler. Return of 'false' stops further processing.
 //	'ehandler' is the parsing error handler. Return of 'false' stops further processing and returns error.
 //	Note: phandler() and ehandler() calls are blocking, so reading and processing of messages is serialized.
 //	      This means that you can stop reading the file on error or after processing a particular message.
 //	      To have reading and handling run concurrently, pass arguments to a go routine in handler and return true.
+//	      Or set the Decoder Pipeline to decode messages concurrently and handle them in order.
+//	      After a malformed message, the error is a *BadMsgError and reading resumes at the next message.
 func XmlMsgsFromFileAsJson(fname string, phandler func(string)(bool), ehandler func(error)(bool), recast ...bool) error {
-	var r bool
-	if len(recast) == 1 {
-		r = recast[0]
-	}
-	fi, fierr := os.Stat(fname)
-	if fierr != nil {
-		return fierr
-	}
-	fh, fherr := os.Open(fname)
-	if fherr != nil {
-		return fherr
+	return defaultDecoder(recast).XmlMsgsFromFileAsJson(fname, phandler, ehandler)
+}
+
+// (*Decoder)XmlMsgsFromFileAsJson() - XmlMsgsFromFileAsJson using the Decoder settings.
+func (d *Decoder) XmlMsgsFromFileAsJson(fname string, phandler func(string)(bool), ehandler func(error)(bool)) error {
+	return d.xmlMsgsFromFileAsJson(context.Background(), fname, phandler, ehandler)
+}
+
+// (*Decoder)xmlMsgsFromFileAsJson() - XmlMsgsFromFileAsJson, checking 'ctx' between tokens and messages.
+func (d *Decoder) xmlMsgsFromFileAsJson(ctx context.Context, fname string, phandler func(string)(bool), ehandler func(error)(bool)) error {
+	fh, err := openMsgsFile(fname, 0)
+	if err != nil {
+		return err
 	}
 	defer fh.Close()
-	buf := make([]byte,fi.Size())
-	_, rerr  :=  fh.Read(buf)
-	if rerr != nil {
-		return rerr
-	}
-	doc := string(buf)
-
-	// xml.Decoder doesn't properly handle whitespace in some doc
-	// see songTextString.xml test case ... 
-	reg,_ := regexp.Compile("[ \t\n\r]*<")
-	doc = reg.ReplaceAllString(doc,"<")
-	b := bytes.NewBufferString(doc)
-
-	for {
-		s, serr := XmlBufferToJson(b,r)
-		if serr != nil && serr != io.EOF {
-			if ok := ehandler(serr); !ok {
-				// caused reader termination
-				return serr
-			 }
-		}
-		if s != "" {
-			if ok := phandler(s); !ok {
-				break
-			}
-		}
-		if serr == io.EOF {
-			break
-		}
-	}
-	return nil
+	return d.xmlMsgsFromReaderAsJson(ctx, fh, phandler, ehandler)
 }
 
 // XmlBufferToJson - process XML message from a bytes.Buffer
 //	'b' is the buffer
 //	Optional argument 'recast' coerces values to float64 or bool where possible.
 func XmlBufferToJson(b *bytes.Buffer,recast ...bool) (string,error) {
-	var r bool
-	if len(recast) == 1 {
-		r = recast[0]
-	}
+	return defaultDecoder(recast).XmlBufferToJson(b)
+}
 
-	n,err := XmlBufferToTree(b)
+// (*Decoder)XmlBufferToJson - process XML message from a bytes.Buffer using the Decoder settings.
+func (d *Decoder) XmlBufferToJson(b *bytes.Buffer) (string, error) {
+	return d.xmlBufferToJson(context.Background(), b)
+}
+
+// (*Decoder)xmlBufferToJson - XmlBufferToJson, checking 'ctx' between tokens.
+func (d *Decoder) xmlBufferToJson(ctx context.Context, b *bytes.Buffer) (string, error) {
+	n, err := d.xmlBufferToTree(ctx, b)
 	if err != nil {
 		return "", err
 	}
 
-	m := make(map[string]interface{})
-	m[n.key] = n.treeToMap(r)
-
-	j, jerr := json.Marshal(m)
-	return string(j), jerr
+	return n.ToJson(d)
 }
 
 // =============================  io.Reader version for stream processing  ======================
@@ -99,29 +73,35 @@ func XmlBufferToJson(b *bytes.Buffer,recast ...bool) (string,error) {
 //	Note: phandler() and ehandler() calls are blocking, so reading and processing of messages is serialized.
 //	      This means that you can stop reading the file on error or after processing a particular message.
 //	      To have reading and handling run concurrently, pass arguments to a go routine in handler and return true.
+//	      Or set the Decoder Pipeline to decode messages concurrently and handle them in order.
+//	      After a malformed message, the error is a *BadMsgError and reading resumes at the next message.
 func XmlMsgsFromReaderAsJson(rdr io.Reader, phandler func(string)(bool), ehandler func(error)(bool), recast ...bool) error {
-	var r bool
-	if len(recast) == 1 {
-		r = recast[0]
-	}
+	return defaultDecoder(recast).XmlMsgsFromReaderAsJson(rdr, phandler, ehandler)
+}
 
-	for {
-		s, serr := ToJson(rdr,r)
-		if serr != nil && serr != io.EOF {
-			if ok := ehandler(serr); !ok {
-				// caused reader termination
-				return serr
-			 }
-		}
-		if s != "" {
-			if ok := phandler(s); !ok {
-				break
-			}
-		}
-		if serr == io.EOF {
-			break
+// (*Decoder)XmlMsgsFromReaderAsJson() - XmlMsgsFromReaderAsJson using the Decoder settings.
+func (d *Decoder) XmlMsgsFromReaderAsJson(rdr io.Reader, phandler func(string)(bool), ehandler func(error)(bool)) error {
+	return d.xmlMsgsFromReaderAsJson(context.Background(), rdr, phandler, ehandler)
+}
+
+// (*Decoder)xmlMsgsFromReaderAsJson() - XmlMsgsFromReaderAsJson, checking 'ctx' between tokens and messages.
+func (d *Decoder) xmlMsgsFromReaderAsJson(ctx context.Context, rdr io.Reader, phandler func(string)(bool), ehandler func(error)(bool)) error {
+	return d.readMsgs(d.bulkSplitter(ctx, rdr, 0, 0), jsonValue, jsonHandler(phandler, ehandler))
+}
+
+// jsonValue - a 'conv' function for readMsgs(); the JSON string for a message.
+func jsonValue(m map[string]interface{}) (interface{}, error) {
+	j, err := json.Marshal(m)
+	return string(j), err
+}
+
+// jsonHandler - a 'handle' function for readMsgs(), for the AsJson bulk readers.
+func jsonHandler(phandler func(string) bool, ehandler func(error) bool) func(*XmlMsg, interface{}, error) (bool, error) {
+	return func(msg *XmlMsg, v interface{}, err error) (bool, error) {
+		if err != nil {
+			return ehandler(err), err
 		}
+		return phandler(v.(string)), nil
 	}
-	return nil
 }
 
@@ -0,0 +1,128 @@
+// Copyright 2012-2016 Charles Banning. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+//	x2j_context.go: parsing and bulk processing that can be canceled.
+
+package x2j
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+)
+
+// The ...Context functions check 'ctx' between tokens and, for the bulk readers, between messages.
+// If it's canceled or its deadline passes, they return ctx.Err() wrapped with how far they got:
+// while parsing, in a *ParseError with the position reached; for the bulk readers, in a
+// *ContextError as well. Use errors.Is(err, context.Canceled), etc., to test for it.
+//
+// NOTE: a Read of 'rdr' that blocks isn't interrupted; use a source that fails when it's
+// closed or times out - e.g., a net.Conn with a deadline - to unblock it.
+
+// ContextError - a bulk reader stopped because its context was done.
+type ContextError struct {
+	Msgs int   // the messages read, whether handled or passed to ehandler
+	Err  error // ctx.Err() or, if a message was being parsed, a *ParseError wrapping it
+}
+
+func (e *ContextError) Error() string {
+	return "stopped after " + strconv.Itoa(e.Msgs) + " messages: " + e.Err.Error()
+}
+
+func (e *ContextError) Unwrap() error {
+	return e.Err
+}
+
+// contextError - if processing stopped because 'ctx' is done, the *ContextError for 'err'
+// after 'msgs' messages; else nil. Between messages, 'err' is nil.
+func contextError(ctx context.Context, err error, msgs int) error {
+	cerr := ctx.Err()
+	if cerr == nil {
+		return nil
+	}
+	if err == nil {
+		err = cerr
+	} else if !errors.Is(err, cerr) {
+		return nil
+	}
+	return &ContextError{Msgs: msgs, Err: err}
+}
+
+// (*xmlParser)withContext - check 'ctx' between tokens.
+func (p *xmlParser) withContext(ctx context.Context) {
+	if ctx.Done() != nil {
+		p.ctx = ctx
+	}
+}
+
+// ToTreeContext - ToTree, checking 'ctx' between tokens.
+func ToTreeContext(ctx context.Context, rdr io.Reader) (*Node, error) {
+	return defaultDecoder(nil).ToTreeContext(ctx, rdr)
+}
+
+// (*Decoder)ToTreeContext - ToTreeContext using the Decoder settings.
+func (d *Decoder) ToTreeContext(ctx context.Context, rdr io.Reader) (*Node, error) {
+	return d.toTree(ctx, rdr)
+}
+
+// ToMapContext - ToMap, checking 'ctx' between tokens.
+func ToMapContext(ctx context.Context, rdr io.Reader, recast ...bool) (map[string]interface{}, error) {
+	return defaultDecoder(recast).ToMapContext(ctx, rdr)
+}
+
+// (*Decoder)ToMapContext - ToMapContext using the Decoder settings.
+func (d *Decoder) ToMapContext(ctx context.Context, rdr io.Reader) (map[string]interface{}, error) {
+	return d.toMap(ctx, rdr)
+}
+
+// ToJsonContext - ToJson, checking 'ctx' between tokens.
+func ToJsonContext(ctx context.Context, rdr io.Reader, recast ...bool) (string, error) {
+	return defaultDecoder(recast).ToJsonContext(ctx, rdr)
+}
+
+// (*Decoder)ToJsonContext - ToJsonContext using the Decoder settings.
+func (d *Decoder) ToJsonContext(ctx context.Context, rdr io.Reader) (string, error) {
+	return d.toJson(ctx, rdr, false)
+}
+
+// XmlMsgsFromReaderContext - XmlMsgsFromReader, checking 'ctx' between tokens and messages.
+func XmlMsgsFromReaderContext(ctx context.Context, rdr io.Reader, phandler func(map[string]interface{}) bool, ehandler func(error) bool, recast ...bool) error {
+	return defaultDecoder(recast).XmlMsgsFromReaderContext(ctx, rdr, phandler, ehandler)
+}
+
+// (*Decoder)XmlMsgsFromReaderContext - XmlMsgsFromReaderContext using the Decoder settings.
+func (d *Decoder) XmlMsgsFromReaderContext(ctx context.Context, rdr io.Reader, phandler func(map[string]interface{}) bool, ehandler func(error) bool) error {
+	return d.xmlMsgsFromReader(ctx, rdr, phandler, ehandler)
+}
+
+// XmlMsgsFromFileContext - XmlMsgsFromFile, checking 'ctx' between tokens and messages.
+func XmlMsgsFromFileContext(ctx context.Context, fname string, phandler func(map[string]interface{}) bool, ehandler func(error) bool, recast ...bool) error {
+	return defaultDecoder(recast).XmlMsgsFromFileContext(ctx, fname, phandler, ehandler)
+}
+
+// (*Decoder)XmlMsgsFromFileContext - XmlMsgsFromFileContext using the Decoder settings.
+func (d *Decoder) XmlMsgsFromFileContext(ctx context.Context, fname string, phandler func(map[string]interface{}) bool, ehandler func(error) bool) error {
+	return d.xmlMsgsFromFile(ctx, fname, phandler, ehandler)
+}
+
+// XmlMsgsFromReaderAsJsonContext - XmlMsgsFromReaderAsJson, checking 'ctx' between tokens and messages.
+func XmlMsgsFromReaderAsJsonContext(ctx context.Context, rdr io.Reader, phandler func(string) bool, ehandler func(error) bool, recast ...bool) error {
+	return defaultDecoder(recast).XmlMsgsFromReaderAsJsonContext(ctx, rdr, phandler, ehandler)
+}
+
+// (*Decoder)XmlMsgsFromReaderAsJsonContext - XmlMsgsFromReaderAsJsonContext using the Decoder settings.
+func (d *Decoder) XmlMsgsFromReaderAsJsonContext(ctx context.Context, rdr io.Reader, phandler func(string) bool, ehandler func(error) bool) error {
+	return d.xmlMsgsFromReaderAsJson(ctx, rdr, phandler, ehandler)
+}
+
+// XmlMsgsFromFileAsJsonContext - XmlMsgsFromFileAsJson, checking 'ctx' between tokens and messages.
+func XmlMsgsFromFileAsJsonContext(ctx context.Context, fname string, phandler func(string) bool, ehandler func(error) bool, recast ...bool) error {
+	return defaultDecoder(recast).XmlMsgsFromFileAsJsonContext(ctx, fname, phandler, ehandler)
+}
+
+// (*Decoder)XmlMsgsFromFileAsJsonContext - XmlMsgsFromFileAsJsonContext using the Decoder settings.
+func (d *Decoder) XmlMsgsFromFileAsJsonContext(ctx context.Context, fname string, phandler func(string) bool, ehandler func(error) bool) error {
+	return d.xmlMsgsFromFileAsJson(ctx, fname, phandler, ehandler)
+}
@@ -0,0 +1,258 @@
+// Copyright 2012-2016 Charles Banning. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+//	x2j_decoder.go: per-call parsing options.
+
+package x2j
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// Decoder - the settings used to parse XML docs and streams.
+// Build one with NewDecoder(), set the fields you need and use its methods in place of
+// the package-level functions. A Decoder is not changed by parsing, so a configured Decoder
+// can be shared by any number of goroutines; just don't modify its fields while it's in use.
+//
+// The package-level functions - DocToMap(), ToMap(), XmlMsgsFromReader(), etc. - run on a
+// default Decoder that picks up the X2jCharsetReader and CastNanInf() settings.
+type Decoder struct {
+	// Recast - coerce element and attribute values to float64 or bool where possible.
+	Recast bool
+	// CastNanInf - if Recast, also cast "NaN", "Inf" and "-Inf" values to float64.
+	// By default, these values are decoded as 'string'.
+	CastNanInf bool
+	// CharsetReader - if != nil, used to decode docs and streams that aren't UTF-8.
+	// See X2jCharsetReader.
+	CharsetReader func(charset string, input io.Reader) (io.Reader, error)
+	// Namespace - how namespaced element and attribute names are keyed; default is NSLocal.
+	Namespace NamespaceMode
+	// AttrPrefix - prepended to attribute names to form their map keys; if "", it's "-".
+	// E.g., "@" gives "@attr" keys.
+	AttrPrefix string
+	// PlainAttrs - key attributes by their plain names, ignoring AttrPrefix. They can't be
+	// told apart from sub-elements - so 'getAttrs' has no effect in path queries.
+	PlainAttrs bool
+	// TextKey - the map key for the value of an element that has attributes or sub-elements;
+	// if "", it's "#text". E.g., "$t" or "_value".
+	TextKey string
+	// Annotations - the comments, processing instructions, directives and CDATA sections
+	// to keep; by default they're discarded. See Annotation.
+	Annotations Annotation
+	// Mixed - how elements with more than one text segment are decoded; default is MixedDefault.
+	Mixed MixedMode
+	// Whitespace - how whitespace in element values is handled; default is WhitespaceTrim.
+	Whitespace WhitespaceMode
+	// XmlSpace - if true, the values of elements in the scope of an xml:space="preserve"
+	// attribute are handled as for WhitespacePreserve; xml:space="default" restores Whitespace.
+	XmlSpace bool
+	// ForceList - tag names or dot-notation paths from the root, e.g., "book" or "doc.books.book",
+	// of elements that are always decoded as a []interface{} value, even if there's only one.
+	// A "*" in a path matches any tag. See ForceListFromSample() to infer them.
+	ForceList []string
+	// Types - tag names or dot-notation paths, as for ForceList, of elements whose values are
+	// decoded as the type of the TypeHint, whatever the Recast setting. The last key in a path
+	// can be an attribute - e.g., "book.-seq" or "-seq" - with the AttrPrefix. If a value can't
+	// be decoded, parsing fails; the *ParseError wraps a *ConversionError. The value of an empty
+	// element - no text or only whitespace - isn't decoded; it's handled as if it had no hint.
+	Types map[string]TypeHint
+	// Convert - if != nil, called for every element and attribute value ahead of the Types
+	// setting - including the "" of an empty element. 'path' is the keys from the root to the element - only valid during the call;
+	// 'name' is the element name or, if 'isAttr', the attribute name without the AttrPrefix.
+	// A return of (nil, nil) leaves the value to Types and Recast; an error fails parsing
+	// and is wrapped in a *ConversionError.
+	Convert func(path []string, name string, raw string, isAttr bool) (interface{}, error)
+	// Limits - bounds on the depth, size, etc., of each doc or message; by default there
+	// are none. See Limits.
+	Limits Limits
+	// Pipeline - if Pipeline.Workers > 0, the bulk readers decode messages concurrently.
+	// See Pipeline.
+	Pipeline Pipeline
+	// MsgRoot - the name of the root element of the messages read by the bulk readers,
+	// with any prefix as in the XML. After a malformed message, reading resumes at the next
+	// start tag with this name or XML declaration. If "", it's the local name of the root
+	// element of the malformed message, if it was read, or any start tag. See BadMsgError.
+	MsgRoot string
+	// Positions - note where each element and attribute starts in the doc: for trees, see
+	// (*Node)Pos(); for maps, they're under the reserved key PosKey of the top level map:
+	// a map[string]Position keyed by dot-notation path, with the index of list members -
+	// e.g., "doc.book[1].-id". The positions are relative to the start of the file, stream or
+	// buffer, as for ParseError. Maps are then decoded via a tree of nodes, so it's slower.
+	// With MixedSequence, the index of a list member is among the sub-elements of the same
+	// name, not in the SequenceKey list.
+	Positions bool
+}
+
+// NewDecoder - returns a Decoder with the package's default settings; they're those of
+// the zero Decoder, with AttrPrefix and TextKey spelled out.
+func NewDecoder() *Decoder {
+	return &Decoder{AttrPrefix: "-", TextKey: "#text"}
+}
+
+// (*Decoder)attrPrefix - the prefix of attribute keys: AttrPrefix, "-" if it's "", or none if
+// PlainAttrs is set. The zero Decoder has the default settings.
+func (d *Decoder) attrPrefix() string {
+	switch {
+	case d.PlainAttrs:
+		return ""
+	case d.AttrPrefix == "":
+		return "-"
+	}
+	return d.AttrPrefix
+}
+
+// (*Decoder)textKey - the TextKey setting; "#text" if it's "".
+func (d *Decoder) textKey() string {
+	if d.TextKey == "" {
+		return "#text"
+	}
+	return d.TextKey
+}
+
+// defaultDecoder - the Decoder the package-level functions run on.
+// It picks up the X2jCharsetReader and CastNanInf() settings at the time of the call
+// and the optional 'recast' argument of the wrapped function.
+func defaultDecoder(recast []bool) *Decoder {
+	d := NewDecoder()
+	if len(recast) == 1 {
+		d.Recast = recast[0]
+	}
+	d.CastNanInf = castNanInf
+	d.CharsetReader = X2jCharsetReader
+	return d
+}
+
+// (*Decoder)newXmlDecoder - wrap 'rdr' in an xml.Decoder that uses the Decoder settings.
+func (d *Decoder) newXmlDecoder(rdr io.Reader) *xml.Decoder {
+	p := xml.NewDecoder(rdr)
+	p.CharsetReader = d.CharsetReader
+	return p
+}
+
+// xmlParser - the xml.Decoder that xmlToMapParser() and xmlToTree() get tokens from,
+// along with the state that encoding/xml doesn't keep for us.
+type xmlParser struct {
+	*xml.Decoder
+	src      *srcReader // what the xml.Decoder reads
+	own      bool       // 'src' is only read by this parser, which can drop what it records
+	start    int64      // the input offset of 'src' where the doc or message starts
+	cdataOn  bool       // recognize CDATA sections
+	cdata    bool       // the last token is a CDATA section
+	xmlSpace bool       // track xml:space attributes
+	space    []bool     // xml:space="preserve" for each open element
+
+	path      []string    // keys of the open elements, maintained by xmlToMapParser() and xmlToTree()
+	forceList [][]string  // the Decoder ForceList, split
+	types     []typeEntry // the Decoder Types, split
+
+	limits Limits // the Decoder Limits
+	depth  int    // open elements
+	elems  int    // elements so far
+
+	ctx  context.Context // != nil if parsing can be canceled
+	root string          // the local name of the root element, once it's read
+
+	positions bool     // note the positions of the nodes - the Decoder Positions setting
+	pos       Position // where the last token starts, if 'positions'
+	tok       int64    // its input offset in 'src'
+	base      Position // where the doc or message starts in the file, stream or buffer
+}
+
+// (*xmlParser)push - note that element 'key' is open.
+func (p *xmlParser) push(key string) {
+	p.path = append(p.path, key)
+}
+
+// (*xmlParser)pop - note that the current element is closed.
+func (p *xmlParser) pop() {
+	if len(p.path) > 0 {
+		p.path = p.path[:len(p.path)-1]
+	}
+}
+
+// (*xmlParser)Token - xml.Decoder.Token(), noting whether a CharData token is a CDATA section
+// and the xml:space scope, and checking the Decoder Limits and the parser's context.
+func (p *xmlParser) Token() (xml.Token, error) {
+	if p.ctx != nil {
+		if err := p.ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+	if p.cdataOn {
+		p.cdata = p.src.startsWithLT(p.start + p.InputOffset())
+	}
+	if p.positions {
+		off := p.InputOffset()
+		line, col := p.InputPos()
+		p.pos = p.base.at(Position{line, col, off})
+		p.tok = p.start + off
+		if p.own {
+			p.src.from(p.tok)
+		}
+	}
+	t, err := p.Decoder.Token()
+	if err != nil {
+		if p.src.err != nil {
+			// encoding/xml may report it as a syntax error
+			err = p.src.err
+		}
+		return nil, err
+	}
+	if p.root == "" {
+		if se, ok := t.(xml.StartElement); ok {
+			p.root = se.Name.Local
+		}
+	}
+	if p.limits != (Limits{}) {
+		if err = p.checkLimits(t); err != nil {
+			return nil, err
+		}
+	}
+	if p.xmlSpace {
+		p.trackSpace(t)
+	}
+	return t, nil
+}
+
+// (*Decoder)newParser - wrap 'rdr' in an xmlParser that uses the Decoder settings.
+func (d *Decoder) newParser(rdr io.Reader) *xmlParser {
+	src := newSrcReader(rdr)
+	if d.Positions {
+		// for the positions of attributes
+		src.record(true)
+	}
+	p := d.srcParser(src)
+	p.own = true
+	return p
+}
+
+// (*Decoder)srcParser - an xmlParser that reads a doc or message from 'src', from where it is.
+func (d *Decoder) srcParser(src *srcReader) *xmlParser {
+	p := new(xmlParser)
+	p.src = src
+	p.start = src.pos.Offset
+	p.base = src.pos
+	p.cdataOn = d.Annotations&AnnotateCDATA != 0
+	p.positions = d.Positions
+	p.xmlSpace = d.XmlSpace
+	if len(d.ForceList) > 0 {
+		p.forceListPaths(d.ForceList)
+	}
+	if len(d.Types) > 0 {
+		p.typeHints(d.Types)
+	}
+	p.limits = d.Limits
+	src.limit(d.Limits.MaxBytes)
+	p.Decoder = d.newXmlDecoder(src)
+	return p
+}
+
+// (*Decoder)isAttrKey - is 'key' the map key of an attribute?
+func (d *Decoder) isAttrKey(key string) bool {
+	pfx := d.attrPrefix()
+	return pfx != "" && strings.HasPrefix(key, pfx)
+}
@@ -0,0 +1,93 @@
+// Copyright 2012-2016 Charles Banning. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+//	x2j_file.go: read message files, compressed or not.
+
+package x2j
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+)
+
+// msgsFile - a file of messages, decompressed if need be, for the bulk readers.
+type msgsFile struct {
+	*bufio.Reader
+	fh         *os.File
+	gz         *gzip.Reader
+	compressed bool
+}
+
+// openMsgsFile - open 'fname' for reading from input offset 'off'. If it's compressed with gzip
+// or bzip2 - as recognized by the magic bytes it starts with - it's decompressed as it's read
+// and 'off' is an offset in the decompressed input.
+func openMsgsFile(fname string, off int64) (*msgsFile, error) {
+	fh, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	f := &msgsFile{fh: fh}
+	br := bufio.NewReader(fh)
+	magic, _ := br.Peek(3)
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		if f.gz, err = gzip.NewReader(br); err != nil {
+			fh.Close()
+			return nil, err
+		}
+		f.Reader = bufio.NewReader(f.gz)
+		f.compressed = true
+	case bytes.HasPrefix(magic, []byte("BZh")):
+		f.Reader = bufio.NewReader(bzip2.NewReader(br))
+		f.compressed = true
+	default:
+		f.Reader = br
+	}
+	if off > 0 {
+		if err = f.seek(off); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+var errOffset = errors.New("x2j: offset past the end of the file")
+
+// (*msgsFile)seek - skip to input offset 'off'; a compressed file is read up to it.
+func (f *msgsFile) seek(off int64) error {
+	if !f.compressed {
+		fi, err := f.fh.Stat()
+		if err != nil {
+			return err
+		}
+		if off > fi.Size() {
+			return errOffset
+		}
+		if _, err = f.fh.Seek(off, io.SeekStart); err != nil {
+			return err
+		}
+		f.Reader.Reset(f.fh)
+		return nil
+	}
+	if _, err := io.CopyN(io.Discard, f.Reader, off); err != nil {
+		if err == io.EOF {
+			return errOffset
+		}
+		return err
+	}
+	return nil
+}
+
+func (f *msgsFile) Close() error {
+	if f.gz != nil {
+		f.gz.Close()
+	}
+	return f.fh.Close()
+}
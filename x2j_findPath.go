@@ -64,7 +64,10 @@ func PathsForKey(m map[string]interface{}, key string) []string {
 	breadbasket := make(map[string]bool,0)
 	breadcrumb := ""
 
-	hasKeyPath(breadcrumb, m, key, &breadbasket)
+	hasKeyPath(breadcrumb, m, key, func(path string) bool {
+		breadbasket[path] = true
+		return true
+	})
 	if len(breadbasket) == 0 {
 		return nil
 	}
@@ -107,37 +110,21 @@ func PathForKeyShortest(m map[string]interface{}, key string) string {
 	return shortest
 }
 
-// hasKeyPath - if the map 'key' exists append it to KeyPath.path and increment KeyPath.depth
-// This is really just a breadcrumber that saves all trails that hit the prescribed 'key'.
-func hasKeyPath(crumb string, iv interface{}, key string, basket *map[string]bool) {
-	switch iv.(type) {
-	case map[string]interface{}:
-		vv := iv.(map[string]interface{})
-		if _, ok := vv[key]; ok {
-			if crumb == "" {
-				crumb = key
-			} else {
-				crumb += "." + key
-			}
-			// *basket = append(*basket, crumb)
-			(*basket)[crumb] = true
+// hasKeyPath - if the map 'key' exists pass its path to 'yield'; if it returns false, stop and return false.
+// This is really just a breadcrumber that finds all trails that hit the prescribed 'key'.
+// A trail is found more than once if it runs through a list.
+func hasKeyPath(breadcrumb string, iv interface{}, key string, yield func(string) bool) bool {
+	return walkValues(iv, func(at *crumb, k string, v interface{}) WalkAction {
+		if k != key {
+			return WalkContinue
 		}
-		// walk on down the path, key could occur again at deeper node
-		for k, v := range vv {
-			// create a new breadcrumb, add the one we're at to the crumb-trail
-			var nbc string
-			if crumb == "" {
-				nbc = k
-			} else {
-				nbc = crumb + "." + k
-			}
-			hasKeyPath(nbc, v, key, basket)
+		path := at.path(k)
+		if breadcrumb != "" {
+			path = breadcrumb + "." + path
 		}
-	case []interface{}:
-		// crumb-trail doesn't change, pass it on
-		for _, v := range iv.([]interface{}) {
-			hasKeyPath(crumb, v, key, basket)
+		if !yield(path) {
+			return WalkStop
 		}
-	}
+		return WalkContinue
+	})
 }
-
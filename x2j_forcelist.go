@@ -0,0 +1,92 @@
+// Copyright 2012-2016 Charles Banning. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+//	x2j_forcelist.go: decode elements as lists even if they occur only once.
+
+package x2j
+
+import (
+	"sort"
+	"strings"
+)
+
+// (*xmlParser)forceListPaths - parse the Decoder ForceList entries for matching.
+func (p *xmlParser) forceListPaths(list []string) {
+	p.forceList = make([][]string, len(list))
+	for i, v := range list {
+		p.forceList[i] = splitPath(v)
+	}
+}
+
+// (*xmlParser)forced - must the sub-element 'key' of the current element be decoded as a list?
+func (p *xmlParser) forced(key string) bool {
+	for _, f := range p.forceList {
+		if matchPath(f, p.path, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPath - does the ForceList or Types entry 'f' match 'key' with the ancestors 'path'?
+// A tag name matches anywhere in the doc; a path must match from the root.
+// A "*" in a path matches any key.
+func matchPath(f, path []string, key string) bool {
+	if len(f) == 1 {
+		return f[0] == key
+	}
+	if len(f) != len(path)+1 || (f[len(f)-1] != key && f[len(f)-1] != "*") {
+		return false
+	}
+	for i, k := range path {
+		if f[i] != k && f[i] != "*" {
+			return false
+		}
+	}
+	return true
+}
+
+// ListPaths - the paths to the list values in a map decoded from a sample doc.
+// The result can be assigned to the Decoder ForceList setting, so other docs are decoded
+// with the same lists. Paths through list values don't have indexes; e.g., "doc.books.book".
+// The paths are sorted.
+func ListPaths(m map[string]interface{}) []string {
+	basket := make(map[string]bool)
+	walkValues(m, func(at *crumb, key string, v interface{}) WalkAction {
+		if strings.HasPrefix(key, "#") {
+			// #text, #seq, annotations, ...
+			return WalkSkip
+		}
+		if _, ok := v.([]interface{}); ok {
+			basket[at.path(key)] = true
+		}
+		return WalkContinue
+	})
+	paths := make([]string, 0, len(basket))
+	for k := range basket {
+		paths = append(paths, k)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// (*Decoder)ForceListFromSample - add the paths to the list values in the sample 'doc',
+// as decoded using the Decoder settings, to the Decoder ForceList setting. See ListPaths().
+// Call it while setting up the Decoder, not while it's in use.
+func (d *Decoder) ForceListFromSample(doc string) error {
+	m, err := d.DocToMap(doc)
+	if err != nil {
+		return err
+	}
+	have := make(map[string]bool, len(d.ForceList))
+	for _, v := range d.ForceList {
+		have[v] = true
+	}
+	for _, v := range ListPaths(m) {
+		if !have[v] {
+			d.ForceList = append(d.ForceList, v)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+// Copyright 2012-2016 Charles Banning. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+//	x2j_iter.go: range-over-func versions of the bulk readers and map queries.
+
+package x2j
+
+import (
+	"errors"
+	"io"
+	"iter"
+)
+
+// ------------------------------ messages ------------------------------
+
+// XmlMsgsFromReaderSeq - the messages read from 'rdr', as for XmlMsgsFromReader(), as a sequence:
+//	for m, err := range x2j.XmlMsgsFromReaderSeq(rdr) {
+//		if err != nil {
+//			// handle it
+//		}
+//		// process 'm'
+//	}
+// A message is read as each loop iteration starts. As for XmlMsgsFromReader(), a malformed
+// message is a *BadMsgError and the sequence goes on with the next message - break out of the
+// loop to stop; it ends with any other error.
+func XmlMsgsFromReaderSeq(rdr io.Reader, recast ...bool) iter.Seq2[map[string]interface{}, error] {
+	return defaultDecoder(recast).XmlMsgsFromReaderSeq(rdr)
+}
+
+// (*Decoder)XmlMsgsFromReaderSeq - XmlMsgsFromReaderSeq using the Decoder settings.
+func (d *Decoder) XmlMsgsFromReaderSeq(rdr io.Reader) iter.Seq2[map[string]interface{}, error] {
+	return func(yield func(map[string]interface{}, error) bool) {
+		s := d.NewMsgSplitter(rdr)
+		for {
+			msg, err := s.Next()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				if !yieldError(yield, err) {
+					return
+				}
+				continue
+			}
+			if !yield(msg.Map, nil) {
+				return
+			}
+		}
+	}
+}
+
+// yieldError - pass 'err' to 'yield'; true if the sequence goes on: it's a *BadMsgError
+// and the loop didn't break.
+func yieldError(yield func(map[string]interface{}, error) bool, err error) bool {
+	var berr *BadMsgError
+	return yield(nil, err) && errors.As(err, &berr)
+}
+
+// XmlMsgsFromFileSeq - the messages in file 'fname', as for XmlMsgsFromFile(), as a sequence.
+// See XmlMsgsFromReaderSeq().
+func XmlMsgsFromFileSeq(fname string, recast ...bool) iter.Seq2[map[string]interface{}, error] {
+	return defaultDecoder(recast).XmlMsgsFromFileSeq(fname)
+}
+
+// (*Decoder)XmlMsgsFromFileSeq - XmlMsgsFromFileSeq using the Decoder settings.
+func (d *Decoder) XmlMsgsFromFileSeq(fname string) iter.Seq2[map[string]interface{}, error] {
+	return func(yield func(map[string]interface{}, error) bool) {
+		fh, err := openMsgsFile(fname, 0)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer fh.Close()
+		d.XmlMsgsFromReaderSeq(fh)(yield)
+	}
+}
+
+// (*XmlBuffer)MapSeq - the rest of the messages in the buffer, as for NextMap(), as a sequence.
+// See XmlMsgsFromReaderSeq().
+func (buf *XmlBuffer) MapSeq(recast ...bool) iter.Seq2[map[string]interface{}, error] {
+	return func(yield func(map[string]interface{}, error) bool) {
+		for {
+			m, err := buf.NextMap(recast...)
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				if !yieldError(yield, err) {
+					return
+				}
+				continue
+			}
+			if !yield(m, nil) {
+				return
+			}
+		}
+	}
+}
+
+// ------------------------------ map queries ------------------------------
+
+// ValuesForKeySeq - ValuesForKey() as a sequence. The map is searched as the loop runs,
+// so breaking out of the loop ends the search and no list of values is built.
+func ValuesForKeySeq(m map[string]interface{}, key string) iter.Seq[interface{}] {
+	return func(yield func(interface{}) bool) {
+		hasKey(m, key, yield)
+	}
+}
+
+// ValuesFromKeyPathSeq - ValuesFromKeyPath() as a sequence. See ValuesForKeySeq().
+func ValuesFromKeyPathSeq(m map[string]interface{}, path string, getAttrs ...bool) iter.Seq[interface{}] {
+	return defaultDecoder(nil).ValuesFromKeyPathSeq(m, path, getAttrs...)
+}
+
+// (*Decoder)ValuesFromKeyPathSeq - ValuesFromKeyPathSeq for a map decoded using the Decoder settings.
+func (d *Decoder) ValuesFromKeyPathSeq(m map[string]interface{}, path string, getAttrs ...bool) iter.Seq[interface{}] {
+	var a bool
+	if len(getAttrs) == 1 {
+		a = getAttrs[0]
+	}
+	keys := splitPath(path)
+	return func(yield func(interface{}) bool) {
+		d.valuesFromKeyPath(yield, m, keys, a, nil)
+	}
+}
+
+// PathsForKeySeq - PathsForKey() as a sequence. See ValuesForKeySeq().
+// Each path is delivered once, in the order found.
+func PathsForKeySeq(m map[string]interface{}, key string) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		seen := make(map[string]bool)
+		hasKeyPath("", m, key, func(path string) bool {
+			if seen[path] {
+				return true
+			}
+			seen[path] = true
+			return yield(path)
+		})
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2012-2016 Charles Banning. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+//	x2j_limits.go: bounds on the docs and streams that are parsed.
+
+package x2j
+
+import (
+	"encoding/xml"
+	"strconv"
+)
+
+// Limits - bounds on each doc or message a Decoder parses, for XML from sources you don't trust.
+// A zero value is no limit. Parsing stops at the first limit that's exceeded; the *ParseError
+// wraps a *LimitError.
+//
+// MaxBytes is the limit that bounds memory: it's checked as each byte is read, so a huge token is
+// never buffered in full. The others are checked once encoding/xml has returned a token, so a
+// start tag with many attributes or a long text segment has already been buffered when MaxAttrs
+// or MaxText fails; set MaxBytes as well to bound the size of a single token.
+type Limits struct {
+	MaxDepth    int   // nesting depth of elements; the root element is at depth 1
+	MaxBytes    int64 // bytes read from the source
+	MaxElements int   // number of elements
+	MaxAttrs    int   // number of attributes of an element, including xmlns declarations
+	MaxText     int   // length in bytes of a text segment or CDATA section
+}
+
+// LimitError - a Limits setting was exceeded.
+// Test for a particular limit with errors.Is(err, ErrMaxDepth), etc.
+type LimitError struct {
+	Limit string // the name of the Limits field: "MaxDepth", "MaxBytes", ...
+	Max   int64  // its setting
+}
+
+func (e *LimitError) Error() string {
+	return e.Limit + " limit of " + strconv.FormatInt(e.Max, 10) + " exceeded"
+}
+
+// Is - 'target' is a *LimitError for the same limit; e.g., ErrMaxDepth.
+func (e *LimitError) Is(target error) bool {
+	t, ok := target.(*LimitError)
+	return ok && t.Limit == e.Limit
+}
+
+// The errors.Is() targets for each of the Limits.
+var (
+	ErrMaxDepth    error = &LimitError{Limit: "MaxDepth"}
+	ErrMaxBytes    error = &LimitError{Limit: "MaxBytes"}
+	ErrMaxElements error = &LimitError{Limit: "MaxElements"}
+	ErrMaxAttrs    error = &LimitError{Limit: "MaxAttrs"}
+	ErrMaxText     error = &LimitError{Limit: "MaxText"}
+)
+
+// (*xmlParser)checkLimits - is token 't' within the Decoder Limits?
+func (p *xmlParser) checkLimits(t xml.Token) error {
+	switch t.(type) {
+	case xml.StartElement:
+		p.depth++
+		p.elems++
+		if p.limits.MaxDepth > 0 && p.depth > p.limits.MaxDepth {
+			return &LimitError{"MaxDepth", int64(p.limits.MaxDepth)}
+		}
+		if p.limits.MaxElements > 0 && p.elems > p.limits.MaxElements {
+			return &LimitError{"MaxElements", int64(p.limits.MaxElements)}
+		}
+		if p.limits.MaxAttrs > 0 && len(t.(xml.StartElement).Attr) > p.limits.MaxAttrs {
+			return &LimitError{"MaxAttrs", int64(p.limits.MaxAttrs)}
+		}
+	case xml.EndElement:
+		p.depth--
+	case xml.CharData:
+		if p.limits.MaxText > 0 && len(t.(xml.CharData)) > p.limits.MaxText {
+			return &LimitError{"MaxText", int64(p.limits.MaxText)}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+// Copyright 2012-2016 Charles Banning. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+//	x2j_mixed.go: decoding mixed content - text interleaved with sub-elements.
+
+package x2j
+
+import (
+	"strings"
+)
+
+// MixedMode - how Decoder handles elements with more than one text segment; e.g.,
+// <p>Hello <b>big</b> world</p>.
+type MixedMode int
+
+const (
+	// MixedDefault - the text segments overwrite one another, as in earlier releases. A tree
+	// keeps them all as TextNodes, so WriteXml() writes them, but its map values are the same.
+	MixedDefault MixedMode = iota
+	// MixedConcat - the text segments are joined, separated by a space unless the Decoder
+	// Whitespace setting is WhitespacePreserve, into one value:
+	// "p": {"#text": "Hello world", "b": "big"}. Adjacent character data - text and CDATA
+	// sections - isn't separated; only text that a sub-element, comment, etc., came between is.
+	MixedConcat
+	// MixedSequence - the text segments and sub-elements of an element with mixed content are
+	// decoded in document order as a list under SequenceKey. Text is a value in the list and
+	// each sub-element or annotation is a single-key map; attributes are keys of the element
+	// as usual: "p": {"#seq": ["Hello", {"b": "big"}, "world"]}.
+	// Text segments only separated by discarded comments, etc., are joined as for MixedConcat.
+	MixedSequence
+)
+
+// SequenceKey - the reserved key for the contents of an element decoded using MixedSequence.
+const SequenceKey = "#seq"
+
+// (*Decoder)mixedContent - the map value of element 'f', f.n or f.na, for MixedConcat or
+// MixedSequence. f.text is the joined text of the element and f.seq all of its contents,
+// in document order.
+func (d *Decoder) mixedContent(p *xmlParser, f *mapFrame) error {
+	n, na, skey, seq := f.n, f.na, f.skey, f.seq
+	// text is joined, so if there's more than it, there are sub-elements or annotations
+	if d.Mixed == MixedSequence && len(seq) > 1 {
+		delete(n, skey)
+		delete(na, d.textKey())
+		for i, v := range seq {
+			if s, ok := v.(string); ok {
+				var err error
+				if seq[i], err = d.convert(p, "", s); err != nil {
+					return err
+				}
+				continue
+			}
+			for k := range v.(map[string]interface{}) {
+				delete(na, k)
+			}
+		}
+		na[SequenceKey] = seq
+		return nil
+	}
+	v, err := d.convert(p, "", f.text)
+	if err != nil {
+		return err
+	}
+	if len(na) > 0 {
+		delete(n, skey)
+		na[d.textKey()] = v
+	} else {
+		n[skey] = v
+	}
+	return nil
+}
+
+// (*mapFrame)addText - add text segment 's' to the text of 'f', for MixedConcat or MixedSequence.
+func (f *mapFrame) addText(d *Decoder, s string) {
+	f.text = d.joinText(f.text, s, f.gap, f.preserve)
+	if d.Mixed == MixedSequence {
+		if i := len(f.seq) - 1; i >= 0 {
+			if t, ok := f.seq[i].(string); ok {
+				f.seq[i] = d.joinText(t, s, f.gap, f.preserve)
+				f.gap = false
+				return
+			}
+		}
+		f.seq = append(f.seq, s)
+	}
+	f.gap = false
+}
+
+// (*Decoder)joinText - text 's' followed by text segment 't'. If 'gap' - something other than
+// text, like a sub-element or comment, came between them in the doc - they're separated using
+// textSep(); adjacent character data, like text and a CDATA section, isn't.
+func (d *Decoder) joinText(s, t string, gap, preserve bool) string {
+	if s != "" && gap {
+		return s + d.textSep(preserve) + t
+	}
+	return s + t
+}
+
+// (*Node)mixedToMap - nodesToMap for MixedConcat or MixedSequence; 'vals' are the map values
+// of the sub-nodes. If 'n' has no TextNode sub-nodes, the return is (nil, false).
+func (n *Node) mixedToMap(d *Decoder, vals []interface{}) (interface{}, bool) {
+	var text []string
+	var others []*Node
+	var ovals []interface{}
+	var elems bool
+	for i, v := range n.nodes {
+		switch v.kind {
+		case TextNode:
+			text = append(text, v.val)
+			continue
+		case AttrNode:
+		default:
+			elems = true
+		}
+		others = append(others, v)
+		ovals = append(ovals, vals[i])
+	}
+	if len(text) == 0 {
+		return nil, false
+	}
+
+	if d.Mixed == MixedSequence && elems {
+		m := make(map[string]interface{})
+		seq := make([]interface{}, 0)
+		for i, v := range n.nodes {
+			switch v.kind {
+			case AttrNode:
+				m[v.key] = vals[i]
+			case TextNode:
+				seq = append(seq, vals[i])
+			default:
+				seq = append(seq, map[string]interface{}{v.key: vals[i]})
+			}
+		}
+		m[SequenceKey] = seq
+		return m, true
+	}
+
+	v := n.cv
+	if v == nil {
+		v = d.cast(strings.Join(text, d.textSep(n.preserve)))
+	}
+	if len(others) == 0 {
+		return v, true
+	}
+	// decode everything else as usual
+	m := nodesMap(others, ovals)
+	m[d.textKey()] = v
+	return m, true
+}
+
+// (*Node)textToNode - move an element value that's already been parsed to a TextNode,
+// so it stays ahead of the sub-nodes that follow it.
+func (n *Node) textToNode(d *Decoder) {
+	if n.val != "" {
+		n.nodes = append(n.nodes, &Node{key: d.textKey(), val: n.val, kind: TextNode, parent: n})
+		n.val = ""
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2012-2016 Charles Banning. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+//	x2j_msgmeta.go: bulk readers that report where each message is, for checkpoints.
+
+package x2j
+
+import (
+	"context"
+	"io"
+)
+
+// XmlMsgsFromReaderWithMeta() - XmlMsgsFromReader, passing each message to 'phandler' as an
+// *XmlMsg: its map, raw bytes, sequence number and input offsets.
+//	Note: as for XmlMsgsFromReader(), a malformed message is passed to ehandler() as a *BadMsgError,
+//	      which has its sequence number and input offsets.
+func XmlMsgsFromReaderWithMeta(rdr io.Reader, phandler func(*XmlMsg)(bool), ehandler func(error)(bool), recast ...bool) error {
+	return defaultDecoder(recast).XmlMsgsFromReaderWithMeta(rdr, phandler, ehandler)
+}
+
+// (*Decoder)XmlMsgsFromReaderWithMeta() - XmlMsgsFromReaderWithMeta using the Decoder settings.
+func (d *Decoder) XmlMsgsFromReaderWithMeta(rdr io.Reader, phandler func(*XmlMsg)(bool), ehandler func(error)(bool)) error {
+	return d.readMsgs(d.bulkSplitter(context.Background(), rdr, 0, 0), mapValue, metaHandler(phandler, ehandler))
+}
+
+// XmlMsgsFromFileWithMeta() - XmlMsgsFromFile, passing each message to 'phandler' as an *XmlMsg.
+// See XmlMsgsFromReaderWithMeta().
+func XmlMsgsFromFileWithMeta(fname string, phandler func(*XmlMsg)(bool), ehandler func(error)(bool), recast ...bool) error {
+	return defaultDecoder(recast).XmlMsgsFromFileAt(fname, 0, 0, phandler, ehandler)
+}
+
+// (*Decoder)XmlMsgsFromFileWithMeta() - XmlMsgsFromFileWithMeta using the Decoder settings.
+func (d *Decoder) XmlMsgsFromFileWithMeta(fname string, phandler func(*XmlMsg)(bool), ehandler func(error)(bool)) error {
+	return d.XmlMsgsFromFileAt(fname, 0, 0, phandler, ehandler)
+}
+
+// XmlMsgsFromFileAt() - resume XmlMsgsFromFileWithMeta at a checkpoint.
+//	'off' is the input offset to start at - the End of the last message handled
+//	'seq' is the sequence number of the first message read - the Seq of the last message handled, plus 1
+//	Note: offsets are from the start of the file - for a compressed file, of the decompressed input,
+//	      which is read up to 'off' - but ParseError lines and columns are counted from 'off'.
+//	      If 'off' is past the end of the file, nothing is read and an error is returned.
+func XmlMsgsFromFileAt(fname string, off int64, seq int, phandler func(*XmlMsg)(bool), ehandler func(error)(bool), recast ...bool) error {
+	return defaultDecoder(recast).XmlMsgsFromFileAt(fname, off, seq, phandler, ehandler)
+}
+
+// (*Decoder)XmlMsgsFromFileAt() - XmlMsgsFromFileAt using the Decoder settings.
+func (d *Decoder) XmlMsgsFromFileAt(fname string, off int64, seq int, phandler func(*XmlMsg)(bool), ehandler func(error)(bool)) error {
+	fh, err := openMsgsFile(fname, off)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+	return d.readMsgs(d.bulkSplitter(context.Background(), fh, off, seq), mapValue, metaHandler(phandler, ehandler))
+}
+
+// metaHandler - a 'handle' function for readMsgs(), for the WithMeta bulk readers.
+func metaHandler(phandler func(*XmlMsg) bool, ehandler func(error) bool) func(*XmlMsg, interface{}, error) (bool, error) {
+	return func(msg *XmlMsg, v interface{}, err error) (bool, error) {
+		if err != nil {
+			return ehandler(err), err
+		}
+		return phandler(msg), nil
+	}
+}
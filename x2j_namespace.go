@@ -0,0 +1,289 @@
+// Copyright 2012-2016 Charles Banning. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+//	x2j_namespace.go: namespace-aware keys for elements and attributes.
+
+package x2j
+
+import (
+	"encoding/xml"
+	"sort"
+	"strings"
+)
+
+// NamespaceMode - how Decoder keys namespaced elements and attributes.
+type NamespaceMode int
+
+const (
+	// NSLocal - key by local name only: <s:Body> is "Body". (Default.)
+	NSLocal NamespaceMode = iota
+	// NSPrefix - key by prefix as declared in the doc: <s:Body> is "s:Body".
+	// Namespace declarations are kept as "-xmlns" and "-xmlns:s" attributes.
+	NSPrefix
+	// NSExpanded - key by expanded name: <s:Body> is "{http://schemas.xmlsoap.org/soap/envelope/}Body".
+	// Namespace declarations are kept as "-xmlns" and "-xmlns:s" attributes.
+	NSExpanded
+)
+
+const xmlNamespaceURI = "http://www.w3.org/XML/1998/namespace"
+
+// nsScope - the namespace declarations in scope for an element.
+type nsScope struct {
+	parent *nsScope
+	decl   map[string]string // prefix:URI; the default namespace has prefix ""
+}
+
+// (*nsScope)push - return the scope for an element with attributes 'a'.
+// If the element doesn't declare any namespaces, that's the current scope.
+func (ns *nsScope) push(a []xml.Attr) *nsScope {
+	var decl map[string]string
+	for _, v := range a {
+		var prefix string
+		switch {
+		case v.Name.Space == "xmlns":
+			prefix = v.Name.Local
+		case v.Name.Space == "" && v.Name.Local == "xmlns":
+			// default namespace
+		default:
+			continue
+		}
+		if decl == nil {
+			decl = make(map[string]string)
+		}
+		decl[prefix] = v.Value
+	}
+	if decl == nil {
+		return ns
+	}
+	return &nsScope{ns, decl}
+}
+
+// (*nsScope)prefix - the prefix in scope that's bound to 'uri'.
+// Attributes aren't in the default namespace, so isAttr excludes the "" prefix.
+func (ns *nsScope) prefix(uri string, isAttr bool) (string, bool) {
+	if uri == xmlNamespaceURI {
+		return "xml", true
+	}
+	for s := ns; s != nil; s = s.parent {
+		var found []string
+		for p, u := range s.decl {
+			if u != uri || (isAttr && p == "") || ns.shadowed(p, s) {
+				continue
+			}
+			found = append(found, p)
+		}
+		if len(found) > 0 {
+			// same URI bound to more than one prefix - be deterministic
+			sort.Strings(found)
+			return found[0], true
+		}
+	}
+	return "", false
+}
+
+// (*nsScope)docName - name 'n' of an element or attribute in scope 'ns' as it's written in the
+// doc: its prefix, if it has one, is the Space.
+func (ns *nsScope) docName(n xml.Name, isAttr bool) xml.Name {
+	if n.Space == "" || n.Space == "xmlns" {
+		return n
+	}
+	p, ok := ns.prefix(n.Space, isAttr)
+	if !ok {
+		// xml.Decoder leaves an undeclared prefix in n.Space
+		return n
+	}
+	return xml.Name{Space: p, Local: n.Local}
+}
+
+// (*nsScope)shadowed - is 'prefix' declared in 'ns' or a scope between it and 'outer'?
+func (ns *nsScope) shadowed(prefix string, outer *nsScope) bool {
+	for s := ns; s != outer; s = s.parent {
+		if _, ok := s.decl[prefix]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// (*Decoder)nameKey - the map key or Node key for an element or attribute name.
+// The attribute prefix, '-', is not included.
+// 'ns' is the namespace scope of the element; it's ignored for NSLocal.
+func (d *Decoder) nameKey(n xml.Name, ns *nsScope, isAttr bool) string {
+	if d.Namespace == NSLocal || n.Space == "" {
+		return n.Local
+	}
+	if n.Space == "xmlns" {
+		return "xmlns:" + n.Local
+	}
+	p, ok := ns.prefix(n.Space, isAttr)
+	if !ok {
+		// xml.Decoder leaves an undeclared prefix in n.Space
+		return n.Space + ":" + n.Local
+	}
+	if d.Namespace == NSExpanded {
+		return "{" + n.Space + "}" + n.Local
+	}
+	if p == "" {
+		return n.Local
+	}
+	return p + ":" + n.Local
+}
+
+// (*Decoder)pushScope - the namespace scope for an element with attributes 'a'.
+// Scopes are only tracked when they're needed to key names.
+func (d *Decoder) pushScope(ns *nsScope, a []xml.Attr) *nsScope {
+	if d.Namespace == NSLocal {
+		return nil
+	}
+	return ns.push(a)
+}
+
+// ------------------------ matching keys in a path ------------------------
+
+// splitPath - split a dot-notation path into keys.
+// The '.' characters in the URI of an expanded name - "{uri}local" - aren't separators.
+func splitPath(path string) []string {
+	if !strings.Contains(path, "{") {
+		return strings.Split(path, ".")
+	}
+	keys := make([]string, 0)
+	var depth, start int
+	for i := 0; i < len(path); i++ {
+		switch path[i] {
+		case '{':
+			depth++
+		case '}':
+			if depth > 0 {
+				depth--
+			}
+		case '.':
+			if depth == 0 {
+				keys = append(keys, path[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(keys, path[start:])
+}
+
+// splitKey - break a key into its namespace prefix or URI and local name.
+//	"s:Body" ==> "s", "", "Body"
+//	"{uri}Body" ==> "", "uri", "Body"
+//	"Body" ==> "", "", "Body"
+func splitKey(key string) (prefix, uri, local string) {
+	if strings.HasPrefix(key, "{") {
+		if i := strings.Index(key, "}"); i > 0 {
+			return "", key[1:i], key[i+1:]
+		}
+	}
+	if i := strings.Index(key, ":"); i > 0 {
+		return key[:i], "", key[i+1:]
+	}
+	return "", "", key
+}
+
+// (*Decoder)resolvePrefix - look up the URI for 'prefix' from the "-xmlns:prefix" attributes
+// of 'scope' - the innermost map value first.
+func (d *Decoder) resolvePrefix(prefix string, scope []interface{}) (string, bool) {
+	key := d.attrPrefix() + "xmlns"
+	if prefix != "" {
+		key += ":" + prefix
+	}
+	for i := len(scope) - 1; i >= 0; i-- {
+		if m, ok := scope[i].(map[string]interface{}); ok {
+			if v, ok := m[key].(string); ok {
+				return v, true
+			}
+		}
+	}
+	if prefix == "xml" {
+		return xmlNamespaceURI, true
+	}
+	return "", false
+}
+
+// (*Decoder)keyMatches - does the path key 'pkey' match the map key 'mkey' in any of the NamespaceMode forms?
+// A path key with only a local name matches any key with that local name. Otherwise, prefixes are
+// resolved to URIs using the namespace declarations in 'scope' - the enclosing map values - and
+// the value of 'mkey', 'v'. A map key with only a local name is in the default namespace, if one
+// is declared. If the namespaces can't be resolved - e.g., the map was decoded using NSLocal -
+// the local names must match.
+func (d *Decoder) keyMatches(pkey, mkey string, v interface{}, scope []interface{}) bool {
+	if pkey == mkey {
+		return true
+	}
+	// attributes only match attributes
+	isAttr := d.isAttrKey(pkey)
+	if isAttr != d.isAttrKey(mkey) {
+		return false
+	}
+	if isAttr {
+		pkey, mkey = pkey[len(d.attrPrefix()):], mkey[len(d.attrPrefix()):]
+	}
+	pp, pu, pl := splitKey(pkey)
+	mp, mu, ml := splitKey(mkey)
+	if pl != ml {
+		return false
+	}
+	if pp == "" && pu == "" {
+		return true
+	}
+	scope = append(scope[:len(scope):len(scope)], v)
+	var ok bool
+	if mp == "" && mu == "" {
+		// unprefixed attributes aren't in a namespace
+		if isAttr {
+			return true
+		}
+		if pp != "" {
+			if _, ok = d.resolvePrefix(pp, scope); !ok {
+				return true
+			}
+		}
+		if mu, ok = d.resolvePrefix("", scope); !ok {
+			return true
+		}
+	}
+	if pp != "" {
+		if pu, ok = d.resolvePrefix(pp, scope); !ok {
+			return false
+		}
+	}
+	if mp != "" {
+		if mu, ok = d.resolvePrefix(mp, scope); !ok {
+			return false
+		}
+	}
+	return pu == mu
+}
+
+// (*Decoder)valuesForPathKey - the values in 'm' for the path key 'key'.
+// An exact match wins; otherwise all keys that match in some NamespaceMode form
+// are returned, in key order. 'scope' is the map values enclosing 'm'.
+func (d *Decoder) valuesForPathKey(m map[string]interface{}, key string, scope []interface{}) []interface{} {
+	if v, ok := m[key]; ok {
+		return []interface{}{v}
+	}
+	lkey := key
+	if d.isAttrKey(key) {
+		lkey = key[len(d.attrPrefix()):]
+	}
+	_, _, local := splitKey(lkey)
+	scope = append(scope[:len(scope):len(scope)], m)
+	var keys []string
+	for k := range m {
+		if strings.HasSuffix(k, local) && d.keyMatches(key, k, m[k], scope) {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	sort.Strings(keys)
+	vals := make([]interface{}, len(keys))
+	for i, k := range keys {
+		vals[i] = m[k]
+	}
+	return vals
+}
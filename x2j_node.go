@@ -0,0 +1,317 @@
+// Copyright 2012-2016 Charles Banning. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+//	x2j_node.go: navigate a tree of nodes.
+
+package x2j
+
+import (
+	"errors"
+	"strconv"
+)
+
+// (*Node)Name - the element name, as keyed in a map - see the Decoder Namespace setting - or
+// the attribute name, without the Decoder AttrPrefix. For text and annotations it's the reserved
+// key - e.g., the Decoder TextKey or CommentKey.
+func (n *Node) Name() string {
+	return n.key[n.pfx:]
+}
+
+// (*Node)Value - the text value of an element, attribute, text or annotation, as in the doc.
+// If an element has sub-elements, the text after the first of them is in TextNode children -
+// and the text ahead of it too, if the Decoder Mixed setting isn't MixedDefault.
+func (n *Node) Value() string {
+	if n.val != "" || n.kind != ElementNode {
+		return n.val
+	}
+	// an element with attributes keeps its text as a TextNode
+	var s string
+	for _, v := range n.nodes {
+		switch v.kind {
+		case ElementNode:
+			return ""
+		case TextNode:
+			s += v.val
+		}
+	}
+	return s
+}
+
+// (*Node)Parent - the element the node is part of; nil for the root element and its prolog.
+func (n *Node) Parent() *Node {
+	return n.parent
+}
+
+// (*Node)IsAttr - is the node an attribute?
+func (n *Node) IsAttr() bool {
+	return n.attr
+}
+
+// (*Node)IsListMember - does the node have siblings with the same name - or is it forced to be
+// a list by the Decoder ForceList setting - so its value is a member of a list in a map?
+func (n *Node) IsListMember() bool {
+	return n.dup
+}
+
+// (*Node)Attrs - the attributes of an element, in document order.
+func (n *Node) Attrs() []*Node {
+	var a []*Node
+	for _, v := range n.nodes {
+		if v.attr {
+			a = append(a, v)
+		}
+	}
+	return a
+}
+
+// (*Node)Attr - the value of attribute 'name' - without the Decoder AttrPrefix - and whether it's set.
+func (n *Node) Attr(name string) (string, bool) {
+	for _, v := range n.nodes {
+		if v.attr && v.Name() == name {
+			return v.val, true
+		}
+	}
+	return "", false
+}
+
+// (*Node)Children - the sub-elements of an element, and its text and annotations
+// if it has sub-elements, in document order.
+func (n *Node) Children() []*Node {
+	var c []*Node
+	for _, v := range n.nodes {
+		if !v.attr {
+			c = append(c, v)
+		}
+	}
+	return c
+}
+
+// (*Node)FirstChild - the first sub-element of an element named 'name'; nil if there's none.
+func (n *Node) FirstChild(name string) *Node {
+	for _, v := range n.nodes {
+		if v.kind == ElementNode && v.key == name {
+			return v
+		}
+	}
+	return nil
+}
+
+// (*Node)ChildrenNamed - the sub-elements of an element named 'name', in document order.
+func (n *Node) ChildrenNamed(name string) []*Node {
+	var c []*Node
+	for _, v := range n.nodes {
+		if v.kind == ElementNode && v.key == name {
+			c = append(c, v)
+		}
+	}
+	return c
+}
+
+// (*Node)Prolog - the annotations ahead of the root element - see Decoder Annotations;
+// only the root element has them.
+func (n *Node) Prolog() []*Node {
+	return n.prolog
+}
+
+// ------------------------------ editing a tree ------------------------------
+
+// NewElement - an element 'name' with no value, attributes or sub-elements, to add to a tree.
+func NewElement(name string) *Node {
+	return &Node{key: name, nodes: make([]*Node, 0)}
+}
+
+// NewText - text for the mixed content of an element; see AppendChild().
+// It's keyed with the TextKey of the tree it's added to.
+func NewText(text string) *Node {
+	return &Node{key: defaultKeys.text, val: text, kind: TextNode}
+}
+
+// treeKeys - the Decoder TextKey and AttrPrefix a tree was decoded with, kept by its root,
+// so the nodes added to it are keyed as the others are.
+type treeKeys struct {
+	text string
+	attr string
+}
+
+// the keys of a tree that NewElement() starts - those of NewDecoder()
+var defaultKeys = treeKeys{text: "#text", attr: "-"}
+
+// (*Node)treeKeys - the keys of the tree 'n' is part of.
+func (n *Node) treeKeys() *treeKeys {
+	for n.parent != nil {
+		n = n.parent
+	}
+	if n.keys == nil {
+		return &defaultKeys
+	}
+	return n.keys
+}
+
+// (*Node)rekey - key the text and attributes of 'n' and its sub-nodes with 'keys'.
+func (n *Node) rekey(keys *treeKeys) {
+	n.walk(n.key, 0, func(path string, v *Node, depth int) WalkAction {
+		switch v.kind {
+		case TextNode:
+			v.key = keys.text
+		case AttrNode:
+			v.key, v.pfx = keys.attr+v.Name(), len(keys.attr)
+		}
+		return WalkContinue
+	})
+}
+
+// (*Node)SetValue - set the text value of an element, attribute, text or annotation.
+// The text of an element is replaced; if it has sub-elements, it's ahead of them.
+// A value decoded using the Decoder Types setting is dropped.
+func (n *Node) SetValue(v string) {
+	n.val, n.cv = v, nil
+	if n.kind != ElementNode {
+		return
+	}
+	var nodes []*Node
+	for _, c := range n.nodes {
+		if c.kind == TextNode {
+			c.parent = nil
+			continue
+		}
+		nodes = append(nodes, c)
+	}
+	n.nodes = append(n.nodes[:0], nodes...)
+	n.normalize()
+}
+
+// (*Node)SetAttr - set attribute 'name' of an element. A new attribute follows
+// the others and is keyed with the AttrPrefix of the tree.
+func (n *Node) SetAttr(name, value string) {
+	i := 0
+	for ; i < len(n.nodes) && n.nodes[i].attr; i++ {
+		v := n.nodes[i]
+		if v.Name() == name {
+			v.SetValue(value)
+			return
+		}
+	}
+	pfx := n.treeKeys().attr
+	na := &Node{attr: true, kind: AttrNode, key: pfx + name, pfx: len(pfx), val: value, parent: n}
+	n.insertNode(i, na)
+	n.relist(na.key)
+	n.normalize()
+}
+
+// (*Node)RemoveAttr - remove attribute 'name' of an element; false if it isn't set.
+func (n *Node) RemoveAttr(name string) bool {
+	for _, v := range n.nodes {
+		if v.attr && v.Name() == name {
+			return n.RemoveChild(v)
+		}
+	}
+	return false
+}
+
+// (*Node)AppendChild - make 'c' the last child of element 'n'; see Children().
+// If 'c' is part of a tree, it's moved.
+func (n *Node) AppendChild(c *Node) error {
+	return n.InsertChild(len(n.Children()), c)
+}
+
+// (*Node)InsertChild - make 'c' child 'i' of element 'n'; see Children().
+// If 'c' is part of a tree, it's moved.
+func (n *Node) InsertChild(i int, c *Node) error {
+	if n.kind != ElementNode || n.attr {
+		return errors.New("not an element: " + n.key)
+	}
+	if c.attr {
+		return errors.New("can't add an attribute as a child; use SetAttr(): " + c.Name())
+	}
+	for p := n; p != nil; p = p.parent {
+		if p == c {
+			return errors.New("can't add an element to itself: " + c.key)
+		}
+	}
+	nc := len(n.Children())
+	if c.parent == n {
+		nc--
+	}
+	if i < 0 || i > nc {
+		return errors.New("no child: " + strconv.Itoa(i))
+	}
+	if c.parent != nil {
+		c.parent.RemoveChild(c)
+	}
+	if keys := n.treeKeys(); *c.treeKeys() != *keys {
+		c.rekey(keys)
+	}
+	na := len(n.nodes) - len(n.Children()) // the attributes
+	c.parent, c.keys = n, nil
+	n.insertNode(na+i, c)
+	n.relist(c.key)
+	n.normalize()
+	return nil
+}
+
+// (*Node)RemoveChild - remove child or attribute 'c' of element 'n'; false if it isn't one.
+func (n *Node) RemoveChild(c *Node) bool {
+	for i, v := range n.nodes {
+		if v == c {
+			n.nodes = append(n.nodes[:i], n.nodes[i+1:]...)
+			// it's the root of a tree of its own
+			c.keys = n.treeKeys()
+			c.parent = nil
+			c.dup = c.forced
+			n.relist(c.key)
+			n.normalize()
+			return true
+		}
+	}
+	return false
+}
+
+// (*Node)insertNode - insert 'c' at n.nodes[i].
+func (n *Node) insertNode(i int, c *Node) {
+	n.nodes = append(n.nodes, nil)
+	copy(n.nodes[i+1:], n.nodes[i:])
+	n.nodes[i] = c
+}
+
+// (*Node)relist - reset the list membership of the sub-nodes 'key' once one is added or removed;
+// see markDuplicateKeys().
+func (n *Node) relist(key string) {
+	var same []*Node
+	for _, v := range n.nodes {
+		if v.key == key {
+			same = append(same, v)
+		}
+	}
+	for _, v := range same {
+		v.dup = v.forced || len(same) > 1
+	}
+}
+
+// (*Node)normalize - keep the text of an element where decoding does: as its value if it has
+// no attributes or children, and if it has attributes but no sub-elements, as a TextNode.
+func (n *Node) normalize() {
+	if n.kind != ElementNode {
+		return
+	}
+	if len(n.nodes) == 1 && n.nodes[0].kind == TextNode && n.val == "" {
+		t := n.nodes[0]
+		n.val, n.cv, n.nodes = t.val, t.cv, n.nodes[:0]
+		t.parent = nil
+		return
+	}
+	if n.val == "" || len(n.nodes) == 0 {
+		return
+	}
+	i := 0
+	for _, v := range n.nodes {
+		switch v.kind {
+		case ElementNode:
+			return
+		case AttrNode:
+			i++
+		}
+	}
+	n.insertNode(i, &Node{key: n.treeKeys().text, val: n.val, kind: TextNode, cv: n.cv, parent: n})
+	n.val, n.cv = "", nil
+}
@@ -0,0 +1,102 @@
+// Copyright 2012-2016 Charles Banning. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+//	x2j_parseerror.go: where parsing a doc or message failed.
+
+package x2j
+
+import (
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ParseError - parsing failed at Line, Column and Offset - the input byte offset - with the
+// elements in Path open. Err is the underlying error: an *xml.SyntaxError, a *LimitError, a
+// *ConversionError, an error from the source, etc.; use errors.As() or errors.Is() to test for it.
+//
+// The position is relative to the start of the doc or, for the bulk readers and XmlBuffer, of the
+// file, stream or buffer; for a compressed file, it's a position in the decompressed input.
+// If a CharsetReader is in use, it's a position in the UTF-8 decoded input.
+type ParseError struct {
+	Line   int
+	Column int
+	Offset int64
+	Path   []string // keys of the open elements from the root; as for dot-notation paths
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	s := "line " + strconv.Itoa(e.Line) + ", column " + strconv.Itoa(e.Column) +
+		", offset " + strconv.FormatInt(e.Offset, 10)
+	if len(e.Path) > 0 {
+		s += ", in " + strings.Join(e.Path, ".")
+	}
+	return s + ": " + e.Err.Error()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// (*xmlParser)parseError - 'err' as a *ParseError at the current position.
+// io.EOF - no more docs or messages - is returned as is.
+func (p *xmlParser) parseError(err error) error {
+	if err == io.EOF {
+		return err
+	}
+	if _, ok := err.(*ParseError); ok {
+		return err
+	}
+	line, col := p.InputPos()
+	path := make([]string, len(p.path))
+	copy(path, p.path)
+	return &ParseError{Line: line, Column: col, Offset: p.InputOffset(), Path: path, Err: err}
+}
+
+// Position - where something starts in a doc: its line and column, as for ParseError, and
+// input offset. The zero Position is unknown. See (*Node)Pos() and the Decoder Positions setting.
+type Position struct {
+	Line   int
+	Column int
+	Offset int64
+}
+
+var startPos = Position{Line: 1, Column: 1}
+
+// (*Position)advance - the position after 'b' is read.
+func (ps *Position) advance(b ...byte) {
+	for _, c := range b {
+		ps.Offset++
+		if c == '\n' {
+			ps.Line++
+			ps.Column = 1
+		} else {
+			ps.Column++
+		}
+	}
+}
+
+// (Position)at - 'rel', a position relative to the start of the message at 'ps',
+// relative to the start of the file, stream or buffer.
+func (ps Position) at(rel Position) Position {
+	if rel.Line == 1 {
+		rel.Column += ps.Column - 1
+	}
+	rel.Line += ps.Line - 1
+	rel.Offset += ps.Offset
+	return rel
+}
+
+// (Position)rebase - if 'err' is a *ParseError for the message at 'ps', make its
+// position relative to the start of the file, stream or buffer.
+func (ps Position) rebase(err error) error {
+	e, ok := err.(*ParseError)
+	if !ok || ps.Offset == 0 {
+		return err
+	}
+	at := ps.at(Position{e.Line, e.Column, e.Offset})
+	e.Line, e.Column, e.Offset = at.Line, at.Column, at.Offset
+	return e
+}
@@ -0,0 +1,170 @@
+// Copyright 2012-2016 Charles Banning. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+//	x2j_pipeline.go: decode the messages of the bulk readers concurrently.
+
+package x2j
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+)
+
+// Pipeline - how the bulk readers - XmlMsgsFromReader(), XmlMsgsFromFile() and their AsJson and
+// Context variants - decode messages concurrently. One goroutine splits the input into the raw
+// bytes of each message and Workers goroutines decode them; the results are passed to phandler()
+// or ehandler() on the caller's goroutine, one at a time, as before, so the handlers needn't be
+// safe for concurrent use.
+//
+// When a handler stops processing - phandler() or ehandler() returns false - the messages in
+// flight are discarded and the reader returns as soon as the goroutines are done.
+type Pipeline struct {
+	// Workers - the goroutines decoding messages. If 0, messages are read and decoded
+	// one at a time on the caller's goroutine. (Default.)
+	Workers int
+	// Window - the most messages read but not yet handled; default is 2*Workers.
+	// Reading waits while the window is full, so memory use is bounded.
+	Window int
+	// Unordered - pass messages to the handlers as soon as they're decoded,
+	// rather than in input order.
+	Unordered bool
+}
+
+// pipeMsg - a message read by a pipeline; 'v' is its converted value.
+type pipeMsg struct {
+	seq int // in this pipeline
+	msg *XmlMsg
+	v   interface{}
+	err error
+}
+
+// (*Decoder)pipeline - readMsgs() with d.Pipeline.Workers goroutines decoding and converting
+// the messages read from 's'.
+func (d *Decoder) pipeline(s *MsgSplitter, conv func(map[string]interface{}) (interface{}, error),
+	handle func(msg *XmlMsg, v interface{}, err error) (bool, error)) error {
+	window := d.Pipeline.Window
+	if window <= 0 {
+		window = 2 * d.Pipeline.Workers
+	}
+	ctx := s.ctx
+	pctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	s.ctx = pctx
+
+	slots := make(chan struct{}, window) // a message is in flight until it's handled
+	jobs := make(chan *pipeMsg)
+	results := make(chan *pipeMsg, window)
+	var wg sync.WaitGroup
+
+	// the reader
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(jobs)
+		for seq := 0; ; seq++ {
+			select {
+			case slots <- struct{}{}:
+			case <-pctx.Done():
+				return
+			}
+			msg, err := s.split()
+			if err == io.EOF || pctx.Err() != nil {
+				return
+			}
+			m := &pipeMsg{seq: seq, msg: msg, err: err}
+			if err != nil {
+				// it's not passed to a worker
+				results <- m
+				continue
+			}
+			select {
+			case jobs <- m:
+			case <-pctx.Done():
+				return
+			}
+		}
+	}()
+
+	// the workers
+	for i := 0; i < d.Pipeline.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for m := range jobs {
+				var mm map[string]interface{}
+				mm, m.err = d.msgToMap(pctx, m.msg)
+				if m.err == nil {
+					m.msg.Map = mm
+					m.v, m.err = conv(mm)
+				} else if pctx.Err() == nil {
+					m.err = &BadMsgError{Seq: m.msg.Seq, Start: m.msg.Start, End: m.msg.End,
+						Raw: m.msg.Raw, Err: m.msg.pos.rebase(m.err)}
+				}
+				results <- m
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// deliver the results
+	var msgs int
+	var stopped bool
+	var ret error
+	deliver := func(m *pipeMsg) {
+		<-slots
+		if stopped {
+			return
+		}
+		if err := contextError(ctx, m.err, msgs); err != nil {
+			stopped, ret = true, err
+		} else if ok, err := handle(m.msg, m.v, m.err); !ok {
+			stopped, ret = true, err
+		}
+		msgs++
+		if stopped {
+			cancel()
+		}
+	}
+	pending := make(map[int]*pipeMsg)
+	var nextSeq int
+	for m := range results {
+		if d.Pipeline.Unordered {
+			deliver(m)
+			continue
+		}
+		pending[m.seq] = m
+		for {
+			mm, ok := pending[nextSeq]
+			if !ok {
+				break
+			}
+			delete(pending, nextSeq)
+			nextSeq++
+			deliver(mm)
+		}
+	}
+	if !stopped {
+		// the reader quit
+		ret = contextError(ctx, nil, msgs)
+	}
+	return ret
+}
+
+// (*Decoder)msgToMap - decode 'msg', read by (*MsgSplitter)split(). The positions of its nodes
+// are relative to the input it was read from, as for (*MsgSplitter)Next(); errors aren't.
+func (d *Decoder) msgToMap(ctx context.Context, msg *XmlMsg) (map[string]interface{}, error) {
+	p := d.newParser(bytes.NewReader(msg.Raw))
+	p.withContext(ctx)
+	p.base = msg.pos
+	n, err := d.xmlToTree(p)
+	if err != nil {
+		return nil, err
+	}
+	return n.ToMap(d), nil
+}
@@ -0,0 +1,95 @@
+// Copyright 2012-2016 Charles Banning. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+//	x2j_positions.go: where the elements and attributes of a doc start.
+
+package x2j
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+// PosKey - the reserved key of the top level map for the positions of the elements and
+// attributes, if the Decoder Positions setting is on.
+const PosKey = "#pos"
+
+// (*Node)Pos - where the node starts in the doc - the '<' of an element or annotation, or the
+// first byte of an attribute name - relative to the start of the file, stream or buffer as for
+// ParseError. It's only noted if the tree was decoded with the Decoder Positions setting;
+// otherwise, and for text and nodes added by NewElement() or NewText(), it's the zero Position.
+func (n *Node) Pos() Position {
+	return n.pos
+}
+
+// (*Node)positions - the PosKey value for tree 'n': the positions of the elements and attributes
+// keyed by path, as for the Decoder Positions setting.
+func (n *Node) positions() map[string]Position {
+	pm := make(map[string]Position)
+	n.walk(n.key, 0, func(path string, v *Node, depth int) WalkAction {
+		switch {
+		case v.kind != ElementNode && v.kind != AttrNode:
+			return WalkSkip
+		case v.pos.Line > 0:
+			pm[path] = v.pos
+		}
+		return WalkContinue
+	})
+	return pm
+}
+
+// (*xmlParser)attrPos - the positions of the attributes of 't', the start tag just read.
+// If they can't be found - e.g., a CharsetReader is in use - each is the position of the tag.
+func (p *xmlParser) attrPos(t xml.StartElement) []Position {
+	pos := make([]Position, len(t.Attr))
+	tag := p.src.recorded(p.tok)
+	offs := attrOffsets(tag, t.Attr)
+	for i := range pos {
+		pos[i] = p.pos
+		if offs != nil {
+			pos[i].advance(tag[:offs[i]]...)
+		}
+	}
+	return pos
+}
+
+// attrOffsets - the offsets in start tag 'tag' of the names of its attributes 'attrs';
+// nil if 'tag' doesn't match them.
+func attrOffsets(tag []byte, attrs []xml.Attr) []int {
+	if len(tag) == 0 || tag[0] != '<' {
+		return nil
+	}
+	skip := func(i int, stop func(byte) bool) int {
+		for i < len(tag) && !stop(tag[i]) {
+			i++
+		}
+		return i
+	}
+	notSpace := func(c byte) bool { return !isSpace(c) }
+	endName := func(c byte) bool { return isSpace(c) || c == '=' || c == '>' || c == '/' }
+
+	offs := make([]int, len(attrs))
+	i := skip(1, endName)
+	for k, a := range attrs {
+		i = skip(i, notSpace)
+		j := skip(i, endName)
+		name := string(tag[i:j])
+		if name[strings.LastIndex(name, ":")+1:] != a.Name.Local {
+			return nil
+		}
+		offs[k] = i
+		if i = skip(j, notSpace); i >= len(tag) || tag[i] != '=' {
+			return nil
+		}
+		if i = skip(i+1, notSpace); i >= len(tag) || (tag[i] != '"' && tag[i] != '\'') {
+			return nil
+		}
+		q := tag[i]
+		if i = skip(i+1, func(c byte) bool { return c == q }); i >= len(tag) {
+			return nil
+		}
+		i++
+	}
+	return offs
+}
@@ -0,0 +1,135 @@
+// Copyright 2012-2016 Charles Banning. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+//	x2j_resync.go: skip malformed messages in a stream.
+
+package x2j
+
+import (
+	"io"
+	"strconv"
+	"strings"
+)
+
+// BadMsgError - a malformed message read by the bulk readers, XmlBuffer or a MsgSplitter.
+// Raw is what was skipped - from the start of the message up to where reading resumed, at the
+// next XML declaration or start tag for the Decoder MsgRoot - at input offsets Start to End,
+// so it can be set aside. If the Decoder MaxBytes limit is set, Raw is at most that long - only
+// its start is kept - though Start and End still span all that was skipped. Seq is its sequence
+// number, as for XmlMsg. Err is the *ParseError for the message.
+type BadMsgError struct {
+	Seq        int
+	Start, End int64
+	Raw        []byte
+	Err        error
+}
+
+func (e *BadMsgError) Error() string {
+	return "bad message, bytes " + strconv.FormatInt(e.Start, 10) + "-" + strconv.FormatInt(e.End, 10) +
+		": " + e.Err.Error()
+}
+
+func (e *BadMsgError) Unwrap() error {
+	return e.Err
+}
+
+// (*MsgSplitter)resync - after message 'msg' failed with 'err', skip to the next
+// plausible start of a message. What was read for the message is searched first - e.g., if it
+// lacks an end tag, the next message was read as part of it - and then the input.
+// 'root' is the local name of the root element of the message, if it was read.
+// At most the Decoder MaxBytes limit of what's skipped is kept as the Raw of the error.
+func (s *MsgSplitter) resync(msg *XmlMsg, root string, err error) error {
+	if err == io.EOF || s.ctx.Err() != nil {
+		return err
+	}
+	if s.d.MsgRoot != "" {
+		root = s.d.MsgRoot
+	}
+	bad := s.raw() // what's still to be searched
+	max := s.d.Limits.MaxBytes
+	s.r.limit(0)
+	s.r.record(false)
+	var raw []byte // what's been skipped
+	at := msg.pos
+	skip := func() {
+		if max <= 0 || int64(len(raw)) < max {
+			raw = append(raw, bad[0])
+		}
+		at.advance(bad[0])
+		bad = bad[1:]
+	}
+	if len(bad) > 0 {
+		skip()
+	}
+	var eof bool
+	for {
+		if len(bad) > 0 && bad[0] != '<' {
+			skip()
+			continue
+		}
+		ok, more := false, true
+		if len(bad) > 0 {
+			ok, more = msgStart(bad, root)
+		}
+		if more && !eof {
+			b, rerr := s.r.ReadByte()
+			if rerr != nil {
+				eof = true
+			} else {
+				bad = append(bad, b)
+			}
+			continue
+		}
+		if ok || len(bad) == 0 {
+			if len(bad) > 0 {
+				s.r.unread(bad, at)
+			}
+			return &BadMsgError{Seq: msg.Seq, Start: msg.Start, End: at.Offset, Raw: raw, Err: err}
+		}
+		skip()
+	}
+}
+
+// the longest element name recognized
+const maxNameLen = 1024
+
+// msgStart - whether 'b', which starts with '<', starts a message: an XML declaration or a
+// start tag for 'root' - any start tag if root is "". If 'more', more bytes are needed to tell.
+func msgStart(b []byte, root string) (ok, more bool) {
+	const decl = "<?xml"
+	if len(b) < 2 {
+		return false, true
+	}
+	switch {
+	case b[1] == '?':
+		if len(b) <= len(decl) {
+			return false, strings.HasPrefix(decl, string(b))
+		}
+		return string(b[:len(decl)]) == decl && isSpace(b[len(decl)]), false
+	case !isNameStart(b[1]):
+		return false, false
+	}
+	for j := 2; j < len(b) && j <= maxNameLen; j++ {
+		if isSpace(b[j]) || b[j] == '>' || b[j] == '/' {
+			return root == "" || matchRoot(string(b[1:j]), root), false
+		}
+	}
+	return false, len(b) <= maxNameLen
+}
+
+// matchRoot - whether element 'name' is 'root'; if 'root' has no prefix, any prefix matches.
+func matchRoot(name, root string) bool {
+	if name == root {
+		return true
+	}
+	return !strings.Contains(root, ":") && name[strings.LastIndex(name, ":")+1:] == root
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+func isNameStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == ':' || c >= 0x80
+}
@@ -0,0 +1,151 @@
+// Copyright 2012-2016 Charles Banning. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+//	x2j_source.go: the reader under encoding/xml and MsgSplitter.
+
+package x2j
+
+import (
+	"bufio"
+	"errors"
+	"io"
+)
+
+// srcReader - what an xml.Decoder or MsgSplitter reads the XML from. It's an io.ByteScanner,
+// so xml.Decoder reads it a byte at a time and never reads more than one byte beyond the
+// current input offset. As it's read, it:
+//   - tracks the position of what's been read, for MsgSplitter and the positions of nodes;
+//   - fails once the Decoder MaxBytes limit is exceeded, so a huge token isn't buffered in full;
+//   - if recording, keeps what's been read - the raw bytes of a message or a start tag.
+//
+// It can also look at the next byte, to recognize CDATA sections; see startsWithLT().
+type srcReader struct {
+	r    io.ByteReader
+	pos  Position
+	last Position // before the last byte read
+	b    byte     // the last byte read
+	back []byte   // bytes to read again, ahead of 'r'
+	rerr error    // the error reading ahead of 'back'; see startsWithLT()
+
+	max   int64 // if > 0, fail once more than 'max' bytes are read from offset 'start'
+	start int64
+	err   error // the *LimitError, once it's exceeded
+
+	rec    bool   // record what's read in 'buf'
+	buf    []byte // from input offset 'bufOff'
+	bufOff int64
+}
+
+// newSrcReader - a srcReader for 'rdr'; if it isn't an io.ByteReader, it's buffered.
+func newSrcReader(rdr io.Reader) *srcReader {
+	r, ok := rdr.(io.ByteReader)
+	if !ok {
+		r = bufio.NewReader(rdr)
+	}
+	return &srcReader{r: r, pos: startPos}
+}
+
+func (s *srcReader) ReadByte() (byte, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	var b byte
+	if len(s.back) > 0 {
+		b, s.back = s.back[0], s.back[1:]
+	} else if err := s.rerr; err != nil {
+		s.rerr = nil
+		return 0, err
+	} else if b, err = s.r.ReadByte(); err != nil {
+		return 0, err
+	}
+	if s.max > 0 && s.pos.Offset-s.start >= s.max {
+		// it's read again if reading goes on; see (*MsgSplitter)resync()
+		s.back = append([]byte{b}, s.back...)
+		s.err = &LimitError{"MaxBytes", s.max}
+		return 0, s.err
+	}
+	s.last = s.pos
+	s.b = b
+	s.pos.advance(b)
+	if s.rec {
+		s.buf = append(s.buf, b)
+	}
+	return b, nil
+}
+
+func (s *srcReader) UnreadByte() error {
+	if s.pos == s.last {
+		return errors.New("x2j: no byte to unread")
+	}
+	s.unread([]byte{s.b}, s.last)
+	return nil
+}
+
+// (*srcReader)unread - read 'b' again, from 'pos', ahead of what's still to be read.
+// Call it when not recording.
+func (s *srcReader) unread(b []byte, pos Position) {
+	s.back = append(append([]byte(nil), b...), s.back...)
+	s.pos, s.last = pos, pos
+}
+
+// need for io.Reader - xml.Decoder only calls ReadByte
+func (s *srcReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	b, err := s.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	p[0] = b
+	return 1, nil
+}
+
+// (*srcReader)limit - fail once more than 'max' bytes are read from here on; 0 is no limit.
+func (s *srcReader) limit(max int64) {
+	s.max, s.start, s.err = max, s.pos.Offset, nil
+}
+
+// (*srcReader)record - start recording what's read from here on, or stop.
+func (s *srcReader) record(on bool) {
+	s.rec, s.buf, s.bufOff = on, s.buf[:0], s.pos.Offset
+}
+
+// (*srcReader)recorded - what's been recorded from input offset 'off' on.
+func (s *srcReader) recorded(off int64) []byte {
+	if off < s.bufOff || off-s.bufOff > int64(len(s.buf)) {
+		return nil
+	}
+	return s.buf[off-s.bufOff:]
+}
+
+// (*srcReader)from - drop what's been recorded ahead of input offset 'off'.
+func (s *srcReader) from(off int64) {
+	if drop := off - s.bufOff; drop > 0 && drop <= int64(len(s.buf)) {
+		s.buf = append(s.buf[:0], s.buf[drop:]...)
+		s.bufOff = off
+	}
+}
+
+// (*srcReader)startsWithLT - is the byte at input offset 'off' a '<'? Call it before the token
+// at 'off' is read. encoding/xml returns CDATA sections as xml.CharData, but a CharData token
+// that starts with '<' can only be a CDATA section.
+func (s *srcReader) startsWithLT(off int64) bool {
+	switch off {
+	case s.pos.Offset - 1:
+		return s.b == '<'
+	case s.pos.Offset:
+		if len(s.back) == 0 && s.rerr == nil {
+			b, err := s.r.ReadByte()
+			if err != nil {
+				s.rerr = err
+				return false
+			}
+			s.back = append(s.back, b)
+		}
+		return len(s.back) > 0 && s.back[0] == '<'
+	}
+	// input offset is out of step - e.g., a CharsetReader is in use
+	return false
+}
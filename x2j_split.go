@@ -0,0 +1,171 @@
+// Copyright 2012-2016 Charles Banning. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+//	x2j_split.go: split a stream of XML messages into the raw bytes of each message.
+
+package x2j
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+)
+
+// XmlMsg - a message read by a MsgSplitter.
+type XmlMsg struct {
+	Raw []byte                 // the message - any prolog through the end tag of its root element
+	Map map[string]interface{} // its decoded value
+	// Seq - its sequence number; the first message read is 0, and malformed messages count.
+	Seq int
+	// Start, End - the input offsets of its first byte and of the byte after its last;
+	// reading can resume at End. See XmlMsgsFromFileAt().
+	Start, End int64
+	pos        Position
+}
+
+// MsgSplitter - read the messages in a stream one at a time, as raw bytes and decoded.
+// A message ends with the end tag of its root element. Each message can have its own prolog -
+// <?xml ...?> declaration, comments, DOCTYPE - and whatever's between messages up to
+// the next '<' - whitespace, UTF-8 byte order marks, stray text - is skipped.
+//
+// It's what XmlMsgsFromReader(), XmlMsgsFromFile(), their variants and XmlBuffer read messages with.
+type MsgSplitter struct {
+	d   *Decoder
+	ctx context.Context
+	r   *srcReader // records each message
+	seq int        // of the next message
+}
+
+// NewMsgSplitter - a MsgSplitter for 'rdr'. If 'rdr' is an io.ByteReader, it's read no
+// further than the end of the last message; otherwise it's read a byte at a time, as for ToMap().
+//	Optional argument 'recast' coerces map values to float64 or bool where possible.
+func NewMsgSplitter(rdr io.Reader, recast ...bool) *MsgSplitter {
+	return defaultDecoder(recast).NewMsgSplitter(rdr)
+}
+
+// (*Decoder)NewMsgSplitter - NewMsgSplitter decoding messages using the Decoder settings.
+func (d *Decoder) NewMsgSplitter(rdr io.Reader) *MsgSplitter {
+	return d.newMsgSplitter(context.Background(), rdr)
+}
+
+// (*Decoder)newMsgSplitter - NewMsgSplitter, checking 'ctx' between tokens.
+func (d *Decoder) newMsgSplitter(ctx context.Context, rdr io.Reader) *MsgSplitter {
+	if _, ok := rdr.(io.ByteReader); !ok {
+		// see ToTree()
+		rdr = myByteReader(rdr)
+	}
+	return &MsgSplitter{d: d, ctx: ctx, r: newSrcReader(rdr)}
+}
+
+// (*MsgSplitter)Next - the next message. When there are no more messages, the error is io.EOF.
+// If the message is malformed, the error is a *BadMsgError and the next message is read
+// from the next plausible start of a message; see Decoder MsgRoot.
+func (s *MsgSplitter) Next() (*XmlMsg, error) {
+	return s.next(s.d)
+}
+
+// (*MsgSplitter)NextRaw - the raw bytes of the next message, which isn't decoded,
+// so its values aren't converted and the Decoder Limits apply only as it's parsed.
+// See Next().
+func (s *MsgSplitter) NextRaw() ([]byte, error) {
+	msg, err := s.split()
+	if err != nil {
+		return nil, err
+	}
+	return msg.Raw, nil
+}
+
+// (*MsgSplitter)skip - skip what's ahead of the next message; it starts at the next '<'.
+func (s *MsgSplitter) skip() error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	s.r.limit(0)
+	s.r.record(false)
+	for {
+		b, err := s.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if b == '<' {
+			return s.r.UnreadByte()
+		}
+	}
+}
+
+// (*MsgSplitter)start - a parser for the next message.
+func (s *MsgSplitter) start(d *Decoder) (*xmlParser, *XmlMsg, error) {
+	if err := s.skip(); err != nil {
+		return nil, nil, err
+	}
+	s.r.record(true)
+	p := d.srcParser(s.r)
+	p.withContext(s.ctx)
+	msg := &XmlMsg{Seq: s.seq, Start: s.r.pos.Offset, pos: s.r.pos}
+	s.seq++
+	return p, msg, nil
+}
+
+// (*MsgSplitter)raw - a copy of the bytes of the message read.
+func (s *MsgSplitter) raw() []byte {
+	return append([]byte(nil), s.r.buf...)
+}
+
+// (*MsgSplitter)next - the next message, decoded using the settings of 'd'.
+func (s *MsgSplitter) next(d *Decoder) (*XmlMsg, error) {
+	n, msg, err := s.nextTree(d)
+	if err != nil {
+		return nil, err
+	}
+	msg.Map = n.ToMap(d)
+	return msg, nil
+}
+
+// (*MsgSplitter)nextTree - the next message as a tree; its Map isn't set.
+func (s *MsgSplitter) nextTree(d *Decoder) (*Node, *XmlMsg, error) {
+	p, msg, err := s.start(d)
+	if err != nil {
+		return nil, nil, err
+	}
+	n, err := d.xmlToTree(p)
+	if err != nil {
+		return nil, nil, s.resync(msg, p.root, msg.pos.rebase(err))
+	}
+	msg.Raw, msg.End = s.raw(), s.r.pos.Offset
+	return n, msg, nil
+}
+
+// (*MsgSplitter)split - the next message, not decoded; its elements are only tracked
+// so the end tag of its root element is recognized.
+func (s *MsgSplitter) split() (*XmlMsg, error) {
+	p, msg, err := s.start(s.d)
+	if err != nil {
+		return nil, err
+	}
+	var scope []*nsScope // the namespace scopes of the open elements
+	for {
+		t, err := p.Token()
+		if err != nil {
+			return nil, s.resync(msg, p.root, msg.pos.rebase(p.parseError(err)))
+		}
+		switch t.(type) {
+		case xml.StartElement:
+			tt := t.(xml.StartElement)
+			var ns *nsScope
+			if len(scope) > 0 {
+				ns = scope[len(scope)-1]
+			}
+			nns := s.d.pushScope(ns, tt.Attr)
+			p.push(s.d.nameKey(tt.Name, nns, false))
+			scope = append(scope, nns)
+		case xml.EndElement:
+			p.pop()
+			scope = scope[:len(scope)-1]
+			if len(scope) == 0 {
+				msg.Raw, msg.End = s.raw(), s.r.pos.Offset
+				return msg, nil
+			}
+		}
+	}
+}
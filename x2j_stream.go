@@ -0,0 +1,94 @@
+// Copyright 2012-2016 Charles Banning. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+//	x2j_stream.go: extract values from an XML doc without decoding all of it.
+
+package x2j
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+// StreamValuesFromTagPaths - deliver the value of each element of the XML doc read from 'rdr'
+// that matches one of 'paths' to 'handler' as it's parsed, without building a map of the whole doc.
+// Only the matching elements are decoded - each as for DocToMap(), then discarded - so memory use
+// depends on the size of the largest match, not the size of the doc.
+//   'paths' are tag names or dot-notation paths from the root, as for the Decoder ForceList setting:
+//          "Metric" matches the element anywhere in the doc; "Metrics.Metric" only under the root
+//          element "Metrics". A "*" in a path matches any tag; attributes aren't matched.
+//          Elements inside a match aren't delivered separately, even if they match, too.
+//   'handler' is passed the entry of 'paths' that matched and the element value - what
+//          ValuesFromKeyPath() would return for it. Return of 'false' stops further processing.
+//   'recast' is as for DocToMap().
+// It returns when the root element ends; an error is a *ParseError.
+func StreamValuesFromTagPaths(rdr io.Reader, paths []string, handler func(path string, v interface{}) bool, recast ...bool) error {
+	return defaultDecoder(recast).StreamValuesFromTagPaths(rdr, paths, handler)
+}
+
+// (*Decoder)StreamValuesFromTagPaths - StreamValuesFromTagPaths using the Decoder settings.
+func (d *Decoder) StreamValuesFromTagPaths(rdr io.Reader, paths []string, handler func(path string, v interface{}) bool) error {
+	// see ToTree()
+	if _, ok := rdr.(io.ByteReader); !ok {
+		rdr = myByteReader(rdr)
+	}
+	match := make([][]string, len(paths))
+	for i, v := range paths {
+		match[i] = splitPath(v)
+	}
+
+	p := d.newParser(rdr)
+	var scope []*nsScope // the namespace scopes of the open elements
+	for {
+		t, err := p.Token()
+		if err != nil {
+			if err == io.EOF && len(scope) == 0 {
+				// no root element
+				return nil
+			}
+			return p.parseError(err)
+		}
+		switch t.(type) {
+		case xml.StartElement:
+			tt := t.(xml.StartElement)
+			var ns *nsScope
+			if len(scope) > 0 {
+				ns = scope[len(scope)-1]
+			}
+			nns := d.pushScope(ns, tt.Attr)
+			key := d.nameKey(tt.Name, nns, false)
+			i := matchPaths(match, p.path, key)
+			if i < 0 {
+				p.push(key)
+				scope = append(scope, nns)
+				continue
+			}
+			m, err := d.elementToMap(p, key, tt.Attr, nns)
+			if err != nil {
+				return err
+			}
+			if !handler(paths[i], m[key]) || len(scope) == 0 {
+				return nil
+			}
+		case xml.EndElement:
+			p.pop()
+			scope = scope[:len(scope)-1]
+			if len(scope) == 0 {
+				// the root element
+				return nil
+			}
+		}
+	}
+}
+
+// matchPaths - the index of the first entry of 'match' that matches 'key' with the
+// ancestors 'path', or -1; see matchPath().
+func matchPaths(match [][]string, path []string, key string) int {
+	for i, f := range match {
+		if matchPath(f, path, key) {
+			return i
+		}
+	}
+	return -1
+}
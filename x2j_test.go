@@ -39,7 +39,7 @@ func TestX2j(t *testing.T) {
 	fmt.Println("\nDocToTree():\n",root.WriteTree())
 
 	m := make(map[string]interface{})
-	m[root.key] = root.treeToMap(false)
+	m[root.key] = root.treeToMap(NewDecoder())
 	fmt.Println("\ntreeToMap, recast==false:\n",WriteMap(m))
 
 	j,jerr := json.MarshalIndent(m,"","  ")
@@ -0,0 +1,248 @@
+// Copyright 2012-2016 Charles Banning. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+//	x2j_types.go: per-path type hints for element and attribute values.
+
+package x2j
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ValueType - the Go type an element or attribute value is decoded as.
+type ValueType int
+
+const (
+	TypeString  ValueType = iota // string - never recast
+	TypeInt64                    // int64
+	TypeUint64                   // uint64
+	TypeFloat64                  // float64
+	TypeBool                     // bool
+	TypeTime                     // time.Time, parsed using TypeHint.Layout
+	TypeNumber                   // json.Number - keeps all the digits of the value
+)
+
+var typeNames = []string{"string", "int64", "uint64", "float64", "bool", "time.Time", "json.Number"}
+
+func (t ValueType) String() string {
+	if t < 0 || int(t) >= len(typeNames) {
+		return "ValueType(" + strconv.Itoa(int(t)) + ")"
+	}
+	return typeNames[t]
+}
+
+// TypeHint - how the values for a Decoder Types entry are decoded.
+type TypeHint struct {
+	Type ValueType
+	// Layout - for TypeTime, the time.Parse() layout; default is time.RFC3339.
+	Layout string
+}
+
+// ConversionError - a value that couldn't be decoded as the type of its TypeHint
+// or that the Decoder Convert hook returned an error for.
+type ConversionError struct {
+	Path  string // dot-notation path of the element or attribute
+	Value string
+	Type  ValueType // not meaningful if Hook
+	Hook  bool      // the error is from the Decoder Convert hook
+	Err   error
+}
+
+func (e *ConversionError) Error() string {
+	if e.Hook {
+		return "can't convert " + e.Path + " value " + strconv.Quote(e.Value) + ": " + e.Err.Error()
+	}
+	return "can't convert " + e.Path + " value " + strconv.Quote(e.Value) + " to " + e.Type.String() + ": " + e.Err.Error()
+}
+
+func (e *ConversionError) Unwrap() error {
+	return e.Err
+}
+
+// typeEntry - a Decoder Types entry, split for matching.
+type typeEntry struct {
+	path []string
+	hint TypeHint
+}
+
+// (*xmlParser)typeHints - parse the Decoder Types entries for matching.
+// Paths are matched ahead of tag names and each in key order, so the first match wins.
+func (p *xmlParser) typeHints(types map[string]TypeHint) {
+	keys := make([]string, 0, len(types))
+	for k := range types {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		pi, pj := strings.Contains(keys[i], "."), strings.Contains(keys[j], ".")
+		if pi != pj {
+			return pi
+		}
+		return keys[i] < keys[j]
+	})
+	p.types = make([]typeEntry, len(keys))
+	for i, k := range keys {
+		p.types[i] = typeEntry{splitPath(k), types[k]}
+	}
+}
+
+// (*xmlParser)typeHint - the TypeHint for the current element or, if 'attr' != "", its attribute.
+func (p *xmlParser) typeHint(attr string) (*TypeHint, bool) {
+	path, key := p.path, attr
+	if attr == "" {
+		if len(path) == 0 {
+			return nil, false
+		}
+		path, key = path[:len(path)-1], path[len(path)-1]
+	}
+	for i := range p.types {
+		if matchPath(p.types[i].path, path, key) {
+			return &p.types[i].hint, true
+		}
+	}
+	return nil, false
+}
+
+// (*xmlParser)convert - decode 'raw' for the current element or, if 'attr' != "", its attribute
+// using its TypeHint. If there isn't one, 'ok' is false.
+func (p *xmlParser) convert(attr, raw string) (v interface{}, ok bool, err error) {
+	h, ok := p.typeHint(attr)
+	if !ok {
+		return nil, false, nil
+	}
+	if v, err = h.convert(raw); err != nil {
+		return nil, true, &ConversionError{Path: p.pathTo(attr), Value: raw, Type: h.Type, Err: err}
+	}
+	return v, true, nil
+}
+
+// (*xmlParser)pathTo - the dot-notation path of the current element or, if 'attr' != "", its attribute.
+func (p *xmlParser) pathTo(attr string) string {
+	path := strings.Join(p.path, ".")
+	if attr != "" {
+		path += "." + attr
+	}
+	return path
+}
+
+// (*Decoder)decode - decode 'raw' for the current element or, if 'attr' != "", its attribute
+// using the Decoder Convert hook or Types setting. If neither applies, 'ok' is false.
+func (d *Decoder) decode(p *xmlParser, attr, raw string) (v interface{}, ok bool, err error) {
+	if d.Convert != nil && len(p.path) > 0 {
+		name, isAttr := p.path[len(p.path)-1], false
+		if attr != "" {
+			name, isAttr = attr[len(d.attrPrefix()):], true
+		}
+		if v, err = d.Convert(p.path, name, raw, isAttr); err != nil {
+			return nil, true, &ConversionError{Path: p.pathTo(attr), Value: raw, Hook: true, Err: err}
+		}
+		if v != nil {
+			return v, true, nil
+		}
+	}
+	// an empty element - no text or only whitespace - has no value to decode
+	if len(p.types) > 0 && (attr != "" || strings.TrimSpace(raw) != "") {
+		return p.convert(attr, raw)
+	}
+	return nil, false, nil
+}
+
+// (*Decoder)convert - the decoded value of 'raw' for the current element or, if 'attr' != "",
+// its attribute. If neither the Convert hook nor a TypeHint applies, it's cast using the
+// Decoder Recast setting.
+func (d *Decoder) convert(p *xmlParser, attr, raw string) (interface{}, error) {
+	if v, ok, err := d.decode(p, attr, raw); ok {
+		return v, err
+	}
+	return d.cast(raw), nil
+}
+
+// (*TypeHint)convert - decode 'raw' as the hinted type.
+func (h *TypeHint) convert(raw string) (interface{}, error) {
+	if h.Type == TypeString {
+		return raw, nil
+	}
+	s := strings.TrimSpace(raw)
+	switch h.Type {
+	case TypeInt64:
+		return strconv.ParseInt(s, 10, 64)
+	case TypeUint64:
+		return strconv.ParseUint(s, 10, 64)
+	case TypeFloat64:
+		return strconv.ParseFloat(s, 64)
+	case TypeBool:
+		return strconv.ParseBool(s)
+	case TypeTime:
+		layout := h.Layout
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		return time.Parse(layout, s)
+	case TypeNumber:
+		// as encoding/json encodes it - e.g., not "Inf", "+1" or "1_0"
+		if s == "" || s[0] != '-' && (s[0] < '0' || s[0] > '9') || !json.Valid([]byte(s)) {
+			return nil, errors.New("invalid JSON number")
+		}
+		return json.Number(s), nil
+	}
+	return nil, errors.New("unknown ValueType")
+}
+
+// (*Node)convert - decode the values of 'n' and its attributes and text using the Decoder
+// Convert hook or Types setting. 'p' is positioned at 'n'. The decoded values are used by
+// treeToMap(); if the text of 'n' is joined - e.g., for MixedConcat - it's decoded as n.cv.
+func (n *Node) convert(d *Decoder, p *xmlParser) error {
+	if d.Convert == nil && len(p.types) == 0 {
+		return nil
+	}
+	conv := func(nn *Node, attr, raw string) error {
+		v, ok, err := d.decode(p, attr, raw)
+		if ok && err == nil {
+			nn.cv = v
+		}
+		return err
+	}
+	if len(n.nodes) == 0 || n.val != "" {
+		// for MixedDefault, text ahead of the sub-nodes is the value
+		if err := conv(n, "", n.val); err != nil || len(n.nodes) == 0 {
+			return err
+		}
+	}
+	// text is joined as in mixedToMap()
+	join := d.Mixed == MixedConcat
+	if d.Mixed == MixedSequence {
+		join = true
+		for _, v := range n.nodes {
+			if v.kind != AttrNode && v.kind != TextNode {
+				join = false
+				break
+			}
+		}
+	}
+	var text []string
+	for _, v := range n.nodes {
+		var err error
+		switch v.kind {
+		case AttrNode:
+			err = conv(v, v.key, v.val)
+		case TextNode:
+			if join {
+				text = append(text, v.val)
+			} else {
+				err = conv(v, "", v.val)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if len(text) > 0 {
+		return conv(n, "", strings.Join(text, d.textSep(n.preserve)))
+	}
+	return nil
+}
@@ -7,10 +7,6 @@
 
 package x2j
 
-import (
-	"strings"
-)
-
 // ------------------- sweep up everything for some point in the node tree ---------------------
 
 // ValuesAtTagPath - deliver all values at the same level of the document as the specified key.
@@ -26,16 +22,17 @@ import (
 //                "doc.books.*.author" might return all the 'author' tag values as []string - or
 //            		"doc.books.*.author.lastname" might be required, depending on he schema.
 func ValuesAtTagPath(doc, path string, getAttrs ...bool) ([]interface{}, error) {
-	var a bool
-	if len(getAttrs) == 1 {
-		a = getAttrs[0]
-	}
-	m, err := DocToMap(doc)
+	return defaultDecoder(nil).ValuesAtTagPath(doc, path, getAttrs...)
+}
+
+// (*Decoder)ValuesAtTagPath - ValuesAtTagPath using the Decoder settings.
+func (d *Decoder) ValuesAtTagPath(doc, path string, getAttrs ...bool) ([]interface{}, error) {
+	m, err := d.DocToMap(doc)
 	if err != nil {
 		return nil, err
 	}
 
-	v := ValuesAtKeyPath(m, path, a)
+	v := d.ValuesAtKeyPath(m, path, getAttrs...)
 	return v, nil
 }
 
@@ -50,16 +47,21 @@ func ValuesAtTagPath(doc, path string, getAttrs ...bool) ([]interface{}, error)
 //          If a node is '*', then everything beyond is walked.
 //          E.g., see ValuesFromTagPath documentation.
 func ValuesAtKeyPath(m map[string]interface{}, path string, getAttrs ...bool) []interface{} {
+	return defaultDecoder(nil).ValuesAtKeyPath(m, path, getAttrs...)
+}
+
+// (*Decoder)ValuesAtKeyPath - ValuesAtKeyPath for a map decoded using the Decoder settings.
+func (d *Decoder) ValuesAtKeyPath(m map[string]interface{}, path string, getAttrs ...bool) []interface{} {
 	var a bool
 	if len(getAttrs) == 1 {
 		a = getAttrs[0]
 	}
-	keys := strings.Split(path, ".")
+	keys := splitPath(path)
 	lenKeys := len(keys)
 	ret := make([]interface{}, 0)
 	if lenKeys > 1 {
 		// use function in x2j_valuesFrom.go
-		valuesFromKeyPath(&ret, m, keys[:lenKeys-1], a)
+		d.valuesFromKeyPath(appendTo(&ret), m, keys[:lenKeys-1], a, nil)
 		if len(ret) == 0 {
 			return nil
 		}
@@ -76,7 +78,7 @@ func ValuesAtKeyPath(m map[string]interface{}, path string, getAttrs ...bool) []
 	for _, v := range ret {
 		switch v.(type) {
 		case map[string]interface{}:
-			if _, ok := v.(map[string]interface{})[key]; ok {
+			if len(d.valuesForPathKey(v.(map[string]interface{}), key, nil)) > 0 {
 				return ret
 			}
 		}
@@ -6,10 +6,6 @@
 
 package x2j
 
-import (
-	"strings"
-)
-
 // ------------------- sweep up everything for some point in the node tree ---------------------
 
 // ValuesFromTagPath - deliver all values for a path node from a XML doc
@@ -24,16 +20,17 @@ import (
 //                "doc.books.*.author" might return all the 'author' tag values as []string - or
 //            		"doc.books.*.author.lastname" might be required, depending on he schema.
 func ValuesFromTagPath(doc, path string, getAttrs ...bool) ([]interface{}, error) {
-	var a bool
-	if len(getAttrs) == 1 {
-		a = getAttrs[0]
-	}
-	m, err := DocToMap(doc)
+	return defaultDecoder(nil).ValuesFromTagPath(doc, path, getAttrs...)
+}
+
+// (*Decoder)ValuesFromTagPath - ValuesFromTagPath using the Decoder settings.
+func (d *Decoder) ValuesFromTagPath(doc, path string, getAttrs ...bool) ([]interface{}, error) {
+	m, err := d.DocToMap(doc)
 	if err != nil {
 		return nil, err
 	}
 
-	v := ValuesFromKeyPath(m, path, a)
+	v := d.ValuesFromKeyPath(m, path, getAttrs...)
 	return v, nil
 }
 
@@ -41,40 +38,49 @@ func ValuesFromTagPath(doc, path string, getAttrs ...bool) ([]interface{}, error
 // If there are no values for the path 'nil' is returned.
 //   'm' is the map to be walked
 //   'path' is a dot-separated path of key values
+//          Namespaced keys match in any NamespaceMode form - "Body", "s:Body" or "{uri}Body".
 //   'getAttrs' can be set 'true' to return attribute values for "*"-terminated path
 //          If a node is '*', then everything beyond is walked.
 //          E.g., see ValuesFromTagPath documentation.
 func ValuesFromKeyPath(m map[string]interface{}, path string, getAttrs ...bool) []interface{} {
+	return defaultDecoder(nil).ValuesFromKeyPath(m, path, getAttrs...)
+}
+
+// (*Decoder)ValuesFromKeyPath - ValuesFromKeyPath for a map decoded using the Decoder settings.
+// Attribute keys are recognized by the Decoder AttrPrefix.
+func (d *Decoder) ValuesFromKeyPath(m map[string]interface{}, path string, getAttrs ...bool) []interface{} {
 	var a bool
 	if len(getAttrs) == 1 {
 		a = getAttrs[0]
 	}
-	keys := strings.Split(path, ".")
+	keys := splitPath(path)
 	ret := make([]interface{}, 0)
-	valuesFromKeyPath(&ret, m, keys, a)
+	d.valuesFromKeyPath(appendTo(&ret), m, keys, a, nil)
 	if len(ret) == 0 {
 		return nil
 	}
 	return ret
 }
 
-func valuesFromKeyPath(ret *[]interface{}, m interface{}, keys []string, getAttrs bool) {
+// (*Decoder)valuesFromKeyPath - pass the values for 'keys' to 'yield'; if it returns false, stop
+// and return false. 'scope' is the map values enclosing 'm', for resolving namespace prefixes.
+func (d *Decoder) valuesFromKeyPath(yield func(interface{}) bool, m interface{}, keys []string, getAttrs bool, scope []interface{}) bool {
 	lenKeys := len(keys)
 
 	// load 'm' values into 'ret'
 	// expand any lists
 	if lenKeys == 0 {
 		switch m.(type) {
-		case map[string]interface{}:
-			*ret = append(*ret, m)
 		case []interface{}:
 			for _, v := range m.([]interface{}) {
-				*ret = append(*ret, v)
+				if !yield(v) {
+					return false
+				}
 			}
+			return true
 		default:
-			*ret = append(*ret, m)
+			return yield(m)
 		}
-		return
 	}
 
 	// key of interest
@@ -84,10 +90,12 @@ func valuesFromKeyPath(ret *[]interface{}, m interface{}, keys []string, getAttr
 		switch m.(type) {
 		case map[string]interface{}:
 			for k, v := range m.(map[string]interface{}) {
-				if string(k[:1]) == "-" && !getAttrs { // skip attributes?
+				if d.isAttrKey(k) && !getAttrs { // skip attributes?
 					continue
 				}
-				valuesFromKeyPath(ret, v, keys[1:], getAttrs)
+				if !d.valuesFromKeyPath(yield, v, keys[1:], getAttrs, append(scope, m)) {
+					return false
+				}
 			}
 		case []interface{}:
 			for _, v := range m.([]interface{}) {
@@ -95,31 +103,40 @@ func valuesFromKeyPath(ret *[]interface{}, m interface{}, keys []string, getAttr
 				// flatten out a list of maps - keys are processed
 				case map[string]interface{}:
 					for kk, vv := range v.(map[string]interface{}) {
-						if string(kk[:1]) == "-" && !getAttrs { // skip attributes?
+						if d.isAttrKey(kk) && !getAttrs { // skip attributes?
 							continue
 						}
-						valuesFromKeyPath(ret, vv, keys[1:], getAttrs)
+						if !d.valuesFromKeyPath(yield, vv, keys[1:], getAttrs, append(scope, v)) {
+							return false
+						}
 					}
 				default:
-					valuesFromKeyPath(ret, v, keys[1:], getAttrs)
+					if !d.valuesFromKeyPath(yield, v, keys[1:], getAttrs, scope) {
+						return false
+					}
 				}
 			}
 		}
 	default: // key - must be map[string]interface{}
 		switch m.(type) {
 		case map[string]interface{}:
-			if v, ok := m.(map[string]interface{})[key]; ok {
-				valuesFromKeyPath(ret, v, keys[1:], getAttrs)
+			for _, v := range d.valuesForPathKey(m.(map[string]interface{}), key, scope) {
+				if !d.valuesFromKeyPath(yield, v, keys[1:], getAttrs, append(scope, m)) {
+					return false
+				}
 			}
 		case []interface{}: // may be buried in list
 			for _, v := range m.([]interface{}) {
 				switch v.(type) {
 				case map[string]interface{}:
-					if vv, ok := v.(map[string]interface{})[key]; ok {
-						valuesFromKeyPath(ret, vv, keys[1:], getAttrs)
+					for _, vv := range d.valuesForPathKey(v.(map[string]interface{}), key, scope) {
+						if !d.valuesFromKeyPath(yield, vv, keys[1:], getAttrs, append(scope, v)) {
+							return false
+						}
 					}
 				}
 			}
 		}
 	}
+	return true
 }
@@ -0,0 +1,225 @@
+// Copyright 2012-2016 Charles Banning. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+//	x2j_walk.go: visit the values of a map or the nodes of a tree.
+
+package x2j
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WalkAction - what a WalkFunc or NodeWalkFunc returns: whether the walk goes on.
+type WalkAction int
+
+const (
+	WalkContinue WalkAction = iota // visit the sub-values or sub-nodes, then go on
+	WalkSkip                       // don't visit the sub-values or sub-nodes, but go on
+	WalkStop                       // end the walk
+)
+
+// WalkFunc - called by Walk() for each value in a map.
+//	'path' - the dot-notation path of the value from the top level map, with the index of list
+//	         members - e.g., "doc.book[1].title".
+//	'key' - its map key; for a list member, the key of the list.
+//	'depth' - 0 for the values of the top level map; list members are at the depth of the list.
+//	'parent' - the map[string]interface{} it's a value of or, for a list member, the []interface{}.
+type WalkFunc func(path, key string, value interface{}, depth int, parent interface{}) WalkAction
+
+// Walk - call 'fn' for each value in map 'm', in key order and depth first. A list value is
+// visited and then each of its members; if 'fn' returns WalkSkip for it, its members aren't.
+func Walk(m map[string]interface{}, fn WalkFunc) {
+	open := []*walkFrame{newWalkFrame("", m, 0)}
+	for len(open) > 0 {
+		f := open[len(open)-1]
+		var path, key string
+		var v, parent interface{}
+		switch {
+		case f.a != nil && f.next < len(f.a):
+			path, key, v, parent = f.path+"["+strconv.Itoa(f.next)+"]", f.key, f.a[f.next], f.a
+		case f.a == nil && f.next < len(f.keys):
+			key, v, parent = f.keys[f.next], f.m[f.keys[f.next]], f.m
+			path = key
+			if f.path != "" {
+				path = f.path + "." + key
+			}
+		default:
+			open = open[:len(open)-1]
+			continue
+		}
+		f.next++
+		switch fn(path, key, v, f.depth, parent) {
+		case WalkStop:
+			return
+		case WalkSkip:
+			continue
+		}
+		switch v.(type) {
+		case map[string]interface{}:
+			open = append(open, newWalkFrame(path, v.(map[string]interface{}), f.depth+1))
+		case []interface{}:
+			open = append(open, &walkFrame{path: path, key: key, a: v.([]interface{}), depth: f.depth})
+		}
+	}
+}
+
+// walkFrame - a map or list value that Walk() is visiting the sub-values of.
+type walkFrame struct {
+	path  string
+	key   string // of a list
+	m     map[string]interface{}
+	keys  []string // of 'm', sorted
+	a     []interface{}
+	next  int // the next sub-value to visit
+	depth int // of the sub-values
+}
+
+func newWalkFrame(path string, m map[string]interface{}, depth int) *walkFrame {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return &walkFrame{path: path, m: m, keys: keys, depth: depth}
+}
+
+// walkValues - call 'fn' for each value of a map in 'v', a map or list value, and in its
+// sub-values, with the key and where the map is. Unlike Walk(), it's in no particular order,
+// so the keys of the maps aren't sorted, and list members are searched but not visited;
+// it's for lookups, which don't need an order. False if 'fn' stopped the walk.
+func walkValues(v interface{}, fn func(at *crumb, key string, v interface{}) WalkAction) bool {
+	// the maps and lists still to be searched
+	open := []valuesFrame{{nil, v}}
+	for len(open) > 0 {
+		f := open[len(open)-1]
+		open = open[:len(open)-1]
+		switch f.v.(type) {
+		case map[string]interface{}:
+			for k, vv := range f.v.(map[string]interface{}) {
+				switch fn(f.at, k, vv) {
+				case WalkStop:
+					return false
+				case WalkSkip:
+					continue
+				}
+				switch vv.(type) {
+				case map[string]interface{}, []interface{}:
+					open = append(open, valuesFrame{&crumb{f.at, k}, vv})
+				}
+			}
+		case []interface{}:
+			// its members are at the path of its key
+			for _, vv := range f.v.([]interface{}) {
+				switch vv.(type) {
+				case map[string]interface{}, []interface{}:
+					open = append(open, valuesFrame{f.at, vv})
+				}
+			}
+		}
+	}
+	return true
+}
+
+// valuesFrame - a map or list value that walkValues() is still to search.
+type valuesFrame struct {
+	at *crumb
+	v  interface{}
+}
+
+// crumb - where walkValues() found a value: the key of the map or list value that holds it,
+// in the map at 'parent'; nil for the top level map. Paths aren't built unless they're wanted,
+// as they're as long as the depth of the map.
+type crumb struct {
+	parent *crumb
+	key    string
+}
+
+// (*crumb)path - the dot-notation path of 'key' in the map at 'c'.
+func (c *crumb) path(key string) string {
+	keys := []string{key}
+	for ; c != nil; c = c.parent {
+		keys = append(keys, c.key)
+	}
+	for i, j := 0, len(keys)-1; i < j; i, j = i+1, j-1 {
+		keys[i], keys[j] = keys[j], keys[i]
+	}
+	return strings.Join(keys, ".")
+}
+
+// NodeWalkFunc - called by (*Node)Walk() for each node of a tree. 'path' is as for WalkFunc,
+// from the node Walk() is called for, using the keys of the nodes - see Name(); 'depth' is 0
+// for that node and its prolog. The value and parent of 'n' are n.Value() and n.Parent().
+type NodeWalkFunc func(path string, n *Node, depth int) WalkAction
+
+// (*Node)Walk - call 'fn' for node 'n' and each of its sub-nodes - attributes, sub-elements,
+// text and annotations - in document order and depth first; for the root element, its prolog
+// is visited first. Unlike Walk() of the map, it visits every node, whatever its map value.
+func (n *Node) Walk(fn NodeWalkFunc) {
+	if n.parent == nil && len(n.prolog) > 0 {
+		// a key that occurs more than once is a list, as for prologToMap()
+		count := make(map[string]int)
+		for _, v := range n.prolog {
+			count[v.key]++
+		}
+		var idx map[string]int
+		for _, v := range n.prolog {
+			if !v.walk(listPath("", v.key, count[v.key] > 1, &idx), 0, fn) {
+				return
+			}
+		}
+	}
+	n.walk(n.key, 0, fn)
+}
+
+// (*Node)walk - visit 'n', at 'path' and 'depth', and walk its sub-nodes; false if 'fn'
+// stopped the walk.
+func (n *Node) walk(path string, depth int, fn NodeWalkFunc) bool {
+	type frame struct {
+		n    *Node
+		path string
+		next int            // the next sub-node to visit
+		idx  map[string]int // for listPath()
+	}
+	var open []*frame
+	for {
+		switch fn(path, n, depth+len(open)) {
+		case WalkStop:
+			return false
+		case WalkContinue:
+			if len(n.nodes) > 0 {
+				open = append(open, &frame{n: n, path: path})
+			}
+		}
+		// the next sub-node of the innermost node that has one left
+		for len(open) > 0 && open[len(open)-1].next == len(open[len(open)-1].n.nodes) {
+			open = open[:len(open)-1]
+		}
+		if len(open) == 0 {
+			return true
+		}
+		f := open[len(open)-1]
+		n = f.n.nodes[f.next]
+		f.next++
+		path = listPath(f.path, n.key, n.dup, &f.idx)
+	}
+}
+
+// listPath - the path of sub-node 'key' of 'path'; if it's a list member - 'dup' - with its
+// index, counted in 'idx'.
+func listPath(path, key string, dup bool, idx *map[string]int) string {
+	if path != "" {
+		key = path + "." + key
+	}
+	if !dup {
+		return key
+	}
+	if *idx == nil {
+		*idx = make(map[string]int)
+	}
+	i := (*idx)[key]
+	(*idx)[key]++
+	return key + "[" + strconv.Itoa(i) + "]"
+}
@@ -0,0 +1,90 @@
+// Copyright 2012-2016 Charles Banning. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+//	x2j_whitespace.go: handling whitespace in element values.
+
+package x2j
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+// WhitespaceMode - how Decoder handles whitespace in element values.
+//
+// In every mode, text that's only whitespace is dropped if the element has attributes or
+// sub-elements - it's the indentation of the doc. It's kept as the value of an element that
+// has neither - e.g., <code>  </code> - in WhitespacePreserve mode. Attribute values are
+// always kept as is.
+type WhitespaceMode int
+
+const (
+	// WhitespaceTrim - leading and trailing whitespace is removed. (Default.)
+	WhitespaceTrim WhitespaceMode = iota
+	// WhitespacePreserve - text is kept as is: <code>  x</code> is "  x".
+	// Text segments joined by MixedConcat aren't separated by a space.
+	WhitespacePreserve
+	// WhitespaceCollapse - leading and trailing whitespace is removed and each
+	// run of whitespace within the text is replaced by a single space.
+	WhitespaceCollapse
+)
+
+// the whitespace removed by WhitespaceTrim
+const trimChars = "\t\r\b\n "
+
+// (*Decoder)text - the value of the text segment 's' using the Decoder Whitespace setting.
+// If 'preserve', it's in the scope of an xml:space="preserve" attribute.
+// If the value is only whitespace - or empty - 'ok' is false.
+func (d *Decoder) text(s string, preserve bool) (v string, ok bool) {
+	mode := d.Whitespace
+	if preserve {
+		mode = WhitespacePreserve
+	}
+	switch mode {
+	case WhitespacePreserve:
+		v = s
+	case WhitespaceCollapse:
+		v = strings.Join(strings.FieldsFunc(s, isSpaceRune), " ")
+	default:
+		v = strings.Trim(s, trimChars)
+	}
+	return v, strings.Trim(v, trimChars) != ""
+}
+
+// isSpaceRune - is 'r' XML whitespace? Other Unicode spaces, like U+00A0, are text.
+func isSpaceRune(r rune) bool {
+	return r < 0x80 && isSpace(byte(r))
+}
+
+// (*Decoder)textSep - what separates text segments that are joined for MixedConcat.
+// If 'preserve', the element is in the scope of an xml:space="preserve" attribute.
+func (d *Decoder) textSep(preserve bool) string {
+	if preserve || d.Whitespace == WhitespacePreserve {
+		return ""
+	}
+	return " "
+}
+
+// (*xmlParser)trackSpace - maintain the xml:space scope for token 't'.
+func (p *xmlParser) trackSpace(t xml.Token) {
+	switch t.(type) {
+	case xml.StartElement:
+		preserve := p.preserve()
+		for _, a := range t.(xml.StartElement).Attr {
+			if a.Name.Local == "space" && (a.Name.Space == "xml" || a.Name.Space == xmlNamespaceURI) {
+				preserve = a.Value == "preserve"
+			}
+		}
+		p.space = append(p.space, preserve)
+	case xml.EndElement:
+		if len(p.space) > 0 {
+			p.space = p.space[:len(p.space)-1]
+		}
+	}
+}
+
+// (*xmlParser)preserve - is the current element in the scope of xml:space="preserve"?
+func (p *xmlParser) preserve() bool {
+	return len(p.space) > 0 && p.space[len(p.space)-1]
+}
@@ -0,0 +1,180 @@
+// Copyright 2012-2016 Charles Banning. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+//	x2j_writexml.go: write a tree of nodes as XML.
+
+package x2j
+
+import (
+	"bufio"
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// XmlOptions - how (*Node)WriteXml() writes a tree.
+type XmlOptions struct {
+	// Declaration - start with <?xml version="1.0" encoding="UTF-8"?>; a tree doesn't keep it.
+	Declaration bool
+	// Indent - if not "", each element starts on a new line, indented by Indent for each level
+	// of nesting. The content of an element that has text as well as sub-elements isn't
+	// indented, so its text isn't changed.
+	Indent string
+	// SelfClose - write an element with no value or children as <a/> rather than <a></a>.
+	SelfClose bool
+}
+
+// (*Node)WriteXml - write the tree 'n' to 'w' as XML: its prolog - see Decoder Annotations -
+// and the element with its attributes, value and children, in document order. So
+// DocToTree() followed by WriteXml() writes a doc that's equivalent to the original.
+//	Note: names are written as they were in the doc, with their prefixes, whatever the Decoder
+//	      Namespace setting; elements and attributes added by editing the tree are written as
+//	      they're keyed. Values decoded using the Decoder Types setting are written as they
+//	      were in the doc.
+//	      Text is written as it was decoded. With the default WhitespaceTrim, the whitespace
+//	      around the text of mixed content is lost - <p>Hello <b>big</b> world</p> is written
+//	      as <p>Hello<b>big</b>world</p> - so for it to round-trip, decode it with the Decoder
+//	      Whitespace setting WhitespacePreserve. Whitespace-only text isn't kept either way.
+func (n *Node) WriteXml(w io.Writer, opts XmlOptions) error {
+	xw := &xmlWriter{Writer: bufio.NewWriter(w), opts: opts}
+	if opts.Declaration {
+		xw.WriteString(`<?xml version="1.0" encoding="UTF-8"?>`)
+		xw.started = true
+	}
+	for _, v := range n.prolog {
+		xw.node(v, 0, true)
+	}
+	xw.node(n, 0, true)
+	if opts.Indent != "" {
+		xw.WriteByte('\n')
+	}
+	return xw.Flush()
+}
+
+// xmlWriter - the state of (*Node)WriteXml(); write errors are kept by bufio.Writer.
+type xmlWriter struct {
+	*bufio.Writer
+	opts    XmlOptions
+	started bool // something's been written
+}
+
+// (*xmlWriter)newline - start a new line at 'level', if indenting.
+func (xw *xmlWriter) newline(level int) {
+	if xw.opts.Indent == "" {
+		return
+	}
+	if xw.started {
+		xw.WriteByte('\n')
+		xw.WriteString(strings.Repeat(xw.opts.Indent, level))
+	}
+}
+
+func (xw *xmlWriter) escape(s string) {
+	xml.EscapeText(xw, []byte(s))
+}
+
+// openElem - an element (*xmlWriter)node() has written the start tag of.
+type openElem struct {
+	n        *Node
+	children []*Node // sub-nodes other than attributes
+	next     int     // the next one to write
+	mixed    bool    // it has text, so its children aren't indented
+}
+
+// (*xmlWriter)node - write 'n' and its sub-nodes at nesting 'level', on a new line if 'indent'.
+func (xw *xmlWriter) node(n *Node, level int, indent bool) {
+	var open []*openElem
+	for {
+		if e := xw.start(n, level+len(open), indent); e != nil {
+			open = append(open, e)
+		}
+		// close the elements whose sub-nodes have all been written
+		for len(open) > 0 && open[len(open)-1].next == len(open[len(open)-1].children) {
+			e := open[len(open)-1]
+			open = open[:len(open)-1]
+			xw.end(e, level+len(open))
+		}
+		if len(open) == 0 {
+			return
+		}
+		e := open[len(open)-1]
+		n, indent = e.children[e.next], !e.mixed
+		e.next++
+	}
+}
+
+// (*xmlWriter)start - write 'n' - for an element, its start tag and value - at nesting 'level',
+// on a new line if 'indent'. If its sub-nodes and end tag are still to be written, it's returned.
+func (xw *xmlWriter) start(n *Node, level int, indent bool) *openElem {
+	if n.attr {
+		return nil
+	}
+	if indent {
+		xw.newline(level)
+	}
+	xw.started = true
+	switch n.kind {
+	case TextNode:
+		xw.escape(n.val)
+	case CommentNode:
+		xw.WriteString("<!--" + n.val + "-->")
+	case ProcInstNode:
+		xw.WriteString("<?" + n.val + "?>")
+	case DirectiveNode:
+		xw.WriteString("<!" + n.val + ">")
+	case CDATANode:
+		xw.WriteString("<![CDATA[" + strings.Replace(n.val, "]]>", "]]]]><![CDATA[>", -1) + "]]>")
+	default:
+		return xw.element(n)
+	}
+	return nil
+}
+
+// (*xmlWriter)element - write the start tag and value of element 'n'; see start().
+func (xw *xmlWriter) element(n *Node) *openElem {
+	xw.WriteString("<" + n.xmlName())
+	e := &openElem{n: n, mixed: n.val != ""}
+	for _, v := range n.nodes {
+		if v.attr {
+			xw.WriteString(" " + v.xmlName() + `="`)
+			xw.escape(v.val)
+			xw.WriteByte('"')
+			continue
+		}
+		e.children = append(e.children, v)
+		if v.kind == TextNode || v.kind == CDATANode {
+			e.mixed = true
+		}
+	}
+	if n.val == "" && len(e.children) == 0 {
+		if xw.opts.SelfClose {
+			xw.WriteString("/>")
+		} else {
+			xw.WriteString("></" + n.xmlName() + ">")
+		}
+		return nil
+	}
+	xw.WriteByte('>')
+	xw.escape(n.val)
+	return e
+}
+
+// (*xmlWriter)end - write the end tag of 'e', at nesting 'level'.
+func (xw *xmlWriter) end(e *openElem, level int) {
+	if !e.mixed && len(e.children) > 0 {
+		xw.newline(level)
+	}
+	xw.WriteString("</" + e.n.xmlName() + ">")
+}
+
+// (*Node)xmlName - the name of element or attribute 'n' as it's written; see WriteXml().
+func (n *Node) xmlName() string {
+	switch {
+	case n.name.Local == "":
+		return n.Name()
+	case n.name.Space == "":
+		return n.name.Local
+	}
+	return n.name.Space + ":" + n.name.Local
+}
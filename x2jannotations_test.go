@@ -0,0 +1,86 @@
+package x2j
+
+import (
+	"bytes"
+	"testing"
+)
+
+var annotatedDoc = `<?xml version="1.0"?>
+<?xml-stylesheet type="text/xsl" href="style.xsl"?>
+<!DOCTYPE config>
+<config>
+	<!-- the listen address -->
+	<addr>localhost</addr>
+	<!-- 0 is no limit -->
+	<limit>0</limit>
+	<script><![CDATA[ if (a < b) { go(); } ]]></script>
+</config>`
+
+func TestAnnotationsMap(t *testing.T) {
+	d := NewDecoder()
+	d.Annotations = AnnotateAll
+	d.Recast = true
+	m, err := d.DocToMap(annotatedDoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m[ProcInstKey] != `xml-stylesheet type="text/xsl" href="style.xsl"` {
+		t.Fatalf("#pi: %v", m[ProcInstKey])
+	}
+	if m[DirectiveKey] != "DOCTYPE config" {
+		t.Fatalf("#directive: %v", m[DirectiveKey])
+	}
+	c := m["config"].(map[string]interface{})
+	if l, ok := c[CommentKey].([]interface{}); !ok || len(l) != 2 || l[1] != " 0 is no limit " {
+		t.Fatalf("#comment: %v", c[CommentKey])
+	}
+	if c["limit"] != float64(0) {
+		t.Fatalf("limit: %v", c["limit"])
+	}
+	if s := c["script"].(map[string]interface{}); s[CDATAKey] != " if (a < b) { go(); } " {
+		t.Fatalf("script: %v", s)
+	}
+
+	// the tree builder agrees
+	mm, err := d.ToMap(bytes.NewBufferString(annotatedDoc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if WriteMap(mm) == "" || mm[ProcInstKey] != m[ProcInstKey] {
+		t.Fatalf("ToMap: %v", mm)
+	}
+	cc := mm["config"].(map[string]interface{})
+	if l, ok := cc[CommentKey].([]interface{}); !ok || len(l) != 2 || cc["script"].(map[string]interface{})[CDATAKey] != " if (a < b) { go(); } " {
+		t.Fatalf("ToMap config: %v", cc)
+	}
+
+	// by default, annotations are discarded and CDATA is text
+	m, err = DocToMap(annotatedDoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != 1 || m["config"].(map[string]interface{})["script"] != "if (a < b) { go(); }" {
+		t.Fatalf("default: %v", m)
+	}
+}
+
+func TestAnnotationsTree(t *testing.T) {
+	d := NewDecoder()
+	d.Annotations = AnnotateComments | AnnotateCDATA
+	n, err := d.DocToTree(`<doc>text<!--note--><![CDATA[<raw>]]></doc>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := []NodeKind{TextNode, CommentNode, CDATANode}
+	if len(n.nodes) != len(kinds) {
+		t.Fatalf("nodes: %s", n.WriteTree())
+	}
+	for i, k := range kinds {
+		if n.nodes[i].Kind() != k {
+			t.Errorf("node %d: kind %d, want %d", i, n.nodes[i].Kind(), k)
+		}
+	}
+	if n.nodes[2].val != "<raw>" {
+		t.Fatalf("#cdata: %s", n.nodes[2].val)
+	}
+}
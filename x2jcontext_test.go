@@ -0,0 +1,102 @@
+package x2j
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestToMapContext(t *testing.T) {
+	doc := "<doc>\n<a>1</a>\n<b>2</b>\n<c>3</c>\n</doc>"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m, err := ToMapContext(ctx, strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := m["doc"].(map[string]interface{})["c"]; v != "3" {
+		t.Fatalf("c: %v", v)
+	}
+
+	// canceled while parsing
+	d := NewDecoder()
+	d.Convert = func(path []string, name, raw string, isAttr bool) (interface{}, error) {
+		if name == "b" {
+			cancel()
+		}
+		return nil, nil
+	}
+	_, err = d.ToMapContext(ctx, strings.NewReader(doc))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatal(err)
+	}
+	var pe *ParseError
+	if !errors.As(err, &pe) {
+		t.Fatalf("%#v", err)
+	}
+	if pe.Line != 3 || !reflect.DeepEqual(pe.Path, []string{"doc"}) {
+		t.Fatalf("line: %d, path: %v", pe.Line, pe.Path)
+	}
+
+	// canceled ahead of parsing
+	if _, err = ToJsonContext(ctx, strings.NewReader(doc)); !errors.Is(err, context.Canceled) {
+		t.Fatal(err)
+	}
+}
+
+func TestXmlMsgsContext(t *testing.T) {
+	msgs := `<msg>1</msg><msg>2</msg><msg>3</msg><msg>4</msg>`
+	fname := filepath.Join(t.TempDir(), "msgs.xml")
+	if err := os.WriteFile(fname, []byte(msgs), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, run := range []func(context.Context, func()) error{
+		func(ctx context.Context, handled func()) error {
+			return XmlMsgsFromReaderContext(ctx, bytes.NewBufferString(msgs),
+				func(m map[string]interface{}) bool { handled(); return true },
+				func(err error) bool { return true })
+		},
+		func(ctx context.Context, handled func()) error {
+			return XmlMsgsFromFileContext(ctx, fname,
+				func(m map[string]interface{}) bool { handled(); return true },
+				func(err error) bool { return true })
+		},
+		func(ctx context.Context, handled func()) error {
+			return XmlMsgsFromReaderAsJsonContext(ctx, bytes.NewBufferString(msgs),
+				func(s string) bool { handled(); return true },
+				func(err error) bool { return true })
+		},
+		func(ctx context.Context, handled func()) error {
+			return XmlMsgsFromFileAsJsonContext(ctx, fname,
+				func(s string) bool { handled(); return true },
+				func(err error) bool { return true })
+		},
+	} {
+		ctx, cancel := context.WithCancel(context.Background())
+		var n int
+		err := run(ctx, func() {
+			if n++; n == 2 {
+				cancel()
+			}
+		})
+		if n != 2 || !errors.Is(err, context.Canceled) {
+			t.Fatalf("n: %d, err: %v", n, err)
+		}
+		var ce *ContextError
+		if !errors.As(err, &ce) || ce.Msgs != 2 {
+			t.Fatalf("%#v", err)
+		}
+
+		// not canceled
+		n = 0
+		if err = run(context.Background(), func() { n++ }); err != nil || n != 4 {
+			t.Fatalf("n: %d, err: %v", n, err)
+		}
+	}
+}
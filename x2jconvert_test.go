@@ -0,0 +1,89 @@
+package x2j
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestConvertHook(t *testing.T) {
+	payload := base64.StdEncoding.EncodeToString([]byte("hello"))
+	doc := `<item code="X1"><price>$12.50</price><payload>` + payload + `</payload><active>Y</active><qty>3</qty></item>`
+
+	var calls int
+	d := NewDecoder()
+	d.Recast = true
+	d.Convert = func(path []string, name, raw string, isAttr bool) (interface{}, error) {
+		calls++
+		switch {
+		case isAttr:
+			if name != "code" || strings.Join(path, ".") != "item" {
+				t.Errorf("attr: %v %s", path, name)
+			}
+			return strings.ToLower(raw), nil
+		case name == "price":
+			return cast(strings.TrimPrefix(raw, "$"), false), nil
+		case name == "payload":
+			b, err := base64.StdEncoding.DecodeString(raw)
+			return string(b), err
+		case name == "active":
+			return raw == "Y", nil
+		}
+		return nil, nil
+	}
+
+	m, err := d.DocToMap(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mm, err := d.ToMap(bytes.NewBufferString(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 10 {
+		t.Errorf("calls: %d", calls)
+	}
+	for _, v := range []map[string]interface{}{m, mm} {
+		i := v["item"].(map[string]interface{})
+		if i["-code"] != "x1" || i["price"] != 12.5 || i["payload"] != "hello" || i["active"] != true || i["qty"] != float64(3) {
+			t.Errorf("item: %v", i)
+		}
+	}
+
+	// empty elements are converted by both builders
+	d.Convert = func(path []string, name, raw string, isAttr bool) (interface{}, error) {
+		if name == "f" {
+			return "[" + raw + "]", nil
+		}
+		return nil, nil
+	}
+	doc = `<a><f/><f></f><f x="1"/></a>`
+	m, err = d.DocToMap(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := d.DocToTree(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []map[string]interface{}{m, n.ToMap(d)} {
+		if f := v["a"].(map[string]interface{})["f"].([]interface{}); f[0] != "[]" || f[1] != "[]" {
+			t.Errorf("f: %v", f)
+		}
+	}
+
+	d.Convert = func(path []string, name, raw string, isAttr bool) (interface{}, error) {
+		if name == "payload" {
+			_, err := base64.StdEncoding.DecodeString(raw)
+			return nil, err
+		}
+		return nil, nil
+	}
+	_, err = d.DocToMap(`<item><payload>not base64!</payload></item>`)
+	var cerr *ConversionError
+	if !errors.As(err, &cerr) || !cerr.Hook || cerr.Path != "item.payload" {
+		t.Fatalf("err: %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+package x2j
+
+import (
+	"bytes"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestDecoderSettings(t *testing.T) {
+	doc := `<doc><val>NaN</val><num>3.5</num><ok>true</ok></doc>`
+
+	plain := NewDecoder()
+	recast := NewDecoder()
+	recast.Recast = true
+	nanInf := NewDecoder()
+	nanInf.Recast = true
+	nanInf.CastNanInf = true
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			m, err := plain.DocToMap(doc)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if v := m["doc"].(map[string]interface{})["num"]; v != "3.5" {
+				t.Errorf("plain num: %v", v)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			m, err := recast.DocToMap(doc)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mm := m["doc"].(map[string]interface{})
+			if mm["num"] != 3.5 || mm["ok"] != true || mm["val"] != "NaN" {
+				t.Errorf("recast: %v", mm)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			m, err := nanInf.ToMap(bytes.NewBufferString(doc))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if _, ok := m["doc"].(map[string]interface{})["val"].(float64); !ok {
+				t.Errorf("nanInf: %v", m)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestDefaultDecoder(t *testing.T) {
+	d := defaultDecoder([]bool{true})
+	if !d.Recast || d.CastNanInf != castNanInf {
+		t.Fatalf("defaultDecoder: %+v", d)
+	}
+	if d = defaultDecoder(nil); d.Recast {
+		t.Fatal("defaultDecoder: recast with no argument")
+	}
+}
+
+func TestZeroDecoder(t *testing.T) {
+	doc := `<doc a="1"><b c="2">text</b></doc>`
+	var d Decoder
+	m, err := d.DocToMap(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mm, _ := NewDecoder().DocToMap(doc)
+	if !reflect.DeepEqual(m, mm) {
+		t.Fatal(m)
+	}
+	n, err := d.DocToTree(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j, _ := n.ToJson(&d); string(j) != `{"doc":{"-a":"1","b":{"#text":"text","-c":"2"}}}` {
+		t.Fatal(string(j))
+	}
+}
+
+func TestAttrPrefixTextKey(t *testing.T) {
+	doc := `<doc><books><book seq="1">one</book><book seq="2">two</book></books></doc>`
+
+	d := NewDecoder()
+	d.AttrPrefix = "@"
+	d.TextKey = "$t"
+	m, err := d.DocToMap(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := m["doc"].(map[string]interface{})["books"].(map[string]interface{})["book"].([]interface{})
+	if bb := b[1].(map[string]interface{}); bb["@seq"] != "2" || bb["$t"] != "two" {
+		t.Fatalf("book: %v", bb)
+	}
+	a, err := d.NewAttributeMap("seq:2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := d.MapValue(m, "doc.books.book", a); err != nil || v != "two" {
+		t.Fatalf("MapValue: %v, %v", v, err)
+	}
+	if v := d.ValuesFromKeyPath(m, "doc.books.book.*"); len(v) != 2 {
+		t.Fatalf("ValuesFromKeyPath: %v", v)
+	}
+	if v := d.ValuesFromKeyPath(m, "doc.books.book.*", true); len(v) != 4 {
+		t.Fatalf("ValuesFromKeyPath w/ attrs: %v", v)
+	}
+	if n, err := d.DocToTree(doc); err != nil || n.nodes[0].nodes[0].nodes[0].key != "@seq" {
+		t.Fatalf("DocToTree: %v", err)
+	}
+
+	// attributes as plain keys
+	d.PlainAttrs = true
+	if v, err := d.DocValue(doc, "doc.books.book", "seq:1"); err != nil || v != "one" {
+		t.Fatalf("DocValue: %v, %v", v, err)
+	}
+}
@@ -0,0 +1,103 @@
+package x2j
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// bzip2 of "<msg>1</msg>\n<msg>2</msg>\n"
+var bz2Msgs = []byte{
+	0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0x2e, 0xab, 0xc5, 0xec, 0x00, 0x00,
+	0x04, 0x59, 0x80, 0x00, 0x10, 0x00, 0x00, 0xb0, 0x05, 0x00, 0x82, 0x08, 0x00, 0x20, 0x00, 0x20,
+	0xaa, 0x83, 0x6a, 0x64, 0x20, 0xc9, 0x88, 0x88, 0xda, 0x4a, 0x78, 0xc2, 0xd6, 0xc3, 0xe2, 0xee,
+	0x48, 0xa7, 0x0a, 0x12, 0x05, 0xd5, 0x78, 0xbd, 0x80,
+}
+
+func gzipped(t *testing.T, s string) []byte {
+	var b bytes.Buffer
+	w := gzip.NewWriter(&b)
+	if _, err := w.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestMsgsFileCompressed(t *testing.T) {
+	msgs := "<msg>1</msg>\n<msg>2</msg>\n"
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"msgs.xml":     []byte(msgs),
+		"msgs.xml.gz":  gzipped(t, msgs),
+		"msgs.xml.bz2": bz2Msgs,
+	}
+	for name, data := range files {
+		fname := filepath.Join(dir, name)
+		if err := os.WriteFile(fname, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		for _, workers := range []int{0, 2} {
+			d := NewDecoder()
+			d.Recast = true
+			d.Pipeline.Workers = workers
+			var got []interface{}
+			err := d.XmlMsgsFromFile(fname,
+				func(m map[string]interface{}) bool { got = append(got, m["msg"]); return true },
+				func(err error) bool { t.Fatal(name, err); return false })
+			if err != nil || !reflect.DeepEqual(got, []interface{}{float64(1), float64(2)}) {
+				t.Fatalf("%s: %v, %v", name, got, err)
+			}
+			var js []string
+			err = d.XmlMsgsFromFileAsJson(fname,
+				func(s string) bool { js = append(js, s); return true },
+				func(err error) bool { t.Fatal(name, err); return false })
+			if err != nil || !reflect.DeepEqual(js, []string{`{"msg":1}`, `{"msg":2}`}) {
+				t.Fatalf("%s: %v, %v", name, js, err)
+			}
+		}
+		var n int
+		for _, err := range XmlMsgsFromFileSeq(fname) {
+			if err != nil {
+				t.Fatal(name, err)
+			}
+			n++
+		}
+		if n != 2 {
+			t.Fatalf("%s: %d", name, n)
+		}
+	}
+}
+
+func TestMsgsFileErrorPos(t *testing.T) {
+	msgs := "<msg>1</msg>\n<msg>2</msg>\n  <msg><a>3</b></msg>\n"
+	fname := filepath.Join(t.TempDir(), "msgs.xml.gz")
+	if err := os.WriteFile(fname, gzipped(t, msgs), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, workers := range []int{0, 2} {
+		d := NewDecoder()
+		d.Pipeline.Workers = workers
+		err := d.XmlMsgsFromFile(fname,
+			func(m map[string]interface{}) bool { return true },
+			func(err error) bool { return false })
+		var pe *ParseError
+		if !errors.As(err, &pe) || pe.Line != 3 || pe.Column != 16 || pe.Offset != 41 {
+			t.Fatalf("workers: %d, %v", workers, err)
+		}
+	}
+	var lerr error
+	for _, err := range XmlMsgsFromFileSeq(fname) {
+		lerr = err
+	}
+	var pe *ParseError
+	if !errors.As(lerr, &pe) || pe.Line != 3 || pe.Column != 16 {
+		t.Fatal(lerr)
+	}
+}
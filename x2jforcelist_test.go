@@ -0,0 +1,67 @@
+package x2j
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+var oneRecord = `<Response><Result><Record><Code>A</Code></Record></Result><Status>ok</Status></Response>`
+var twoRecords = `<Response><Result><Record><Code>A</Code></Record><Record><Code>B</Code><Code>C</Code></Record></Result></Response>`
+
+func TestForceList(t *testing.T) {
+	for _, list := range [][]string{
+		{"Record"},
+		{"Response.Result.Record"},
+		{"*.*.Record"},
+	} {
+		d := NewDecoder()
+		d.ForceList = list
+		m, err := d.DocToMap(oneRecord)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mm, err := d.ToMap(bytes.NewBufferString(oneRecord))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, v := range []map[string]interface{}{m, mm} {
+			r := v["Response"].(map[string]interface{})["Result"].(map[string]interface{})["Record"]
+			if l, ok := r.([]interface{}); !ok || len(l) != 1 {
+				t.Fatalf("ForceList %v: %v", list, r)
+			}
+			if _, ok := v["Response"].(map[string]interface{})["Status"].(string); !ok {
+				t.Fatalf("ForceList %v: Status: %v", list, v)
+			}
+		}
+	}
+
+	// a path that doesn't match
+	d := NewDecoder()
+	d.ForceList = []string{"Result.Record"}
+	s, err := d.DocToJson(oneRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != `{"Response":{"Result":{"Record":{"Code":"A"}},"Status":"ok"}}` {
+		t.Fatalf("no match: %s", s)
+	}
+}
+
+func TestForceListFromSample(t *testing.T) {
+	d := NewDecoder()
+	if err := d.ForceListFromSample(twoRecords); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"Response.Result.Record", "Response.Result.Record.Code"}
+	if !reflect.DeepEqual(d.ForceList, want) {
+		t.Fatalf("ForceList: %v", d.ForceList)
+	}
+	s, err := d.DocToJson(oneRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != `{"Response":{"Result":{"Record":[{"Code":["A"]}]},"Status":"ok"}}` {
+		t.Fatalf("DocToJson: %s", s)
+	}
+}
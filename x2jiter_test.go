@@ -0,0 +1,152 @@
+package x2j
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestXmlMsgsSeq(t *testing.T) {
+	msgs := `<msg>1</msg><msg>2</msg><msg>3</msg><msg>4</msg>`
+	fname := filepath.Join(t.TempDir(), "msgs.xml")
+	if err := os.WriteFile(fname, []byte(msgs), 0644); err != nil {
+		t.Fatal(err)
+	}
+	buf := NewXmlBuffer(msgs)
+	defer buf.Close()
+
+	rdr := bytes.NewBufferString(msgs)
+	for i, seq := range []func() func(func(map[string]interface{}, error) bool){
+		func() func(func(map[string]interface{}, error) bool) {
+			return XmlMsgsFromReaderSeq(rdr, true)
+		},
+		func() func(func(map[string]interface{}, error) bool) {
+			return buf.MapSeq(true)
+		},
+		func() func(func(map[string]interface{}, error) bool) {
+			// starts over
+			return XmlMsgsFromFileSeq(fname, true)
+		},
+	} {
+		var got []interface{}
+		for m, err := range seq() {
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, m["msg"])
+			if len(got) == 2 {
+				break
+			}
+		}
+		for m, err := range seq() {
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, m["msg"])
+		}
+		want := []interface{}{float64(1), float64(2), float64(3), float64(4)}
+		if i == 2 {
+			want = []interface{}{float64(1), float64(2), float64(1), float64(2), float64(3), float64(4)}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("%v", got)
+		}
+	}
+
+	// goes on after a malformed message, unless the loop breaks
+	bad := `<msg>1</msg><msg>2</bad><msg>3</msg>`
+	var got []interface{}
+	for m, err := range XmlMsgsFromReaderSeq(bytes.NewBufferString(bad)) {
+		var berr *BadMsgError
+		if err != nil && !errors.As(err, &berr) {
+			t.Fatal(err)
+		}
+		got = append(got, m["msg"])
+	}
+	if !reflect.DeepEqual(got, []interface{}{"1", nil, "3"}) {
+		t.Fatal(got)
+	}
+	got = nil
+	for m, err := range NewXmlBuffer(bad).MapSeq() {
+		if err != nil {
+			break
+		}
+		got = append(got, m["msg"])
+	}
+	if !reflect.DeepEqual(got, []interface{}{"1"}) {
+		t.Fatal(got)
+	}
+	// ends with any other error
+	var n int
+	var lerr error
+	cbuf := NewXmlBuffer(msgs)
+	for _, err := range cbuf.MapSeq() {
+		n++
+		lerr = err
+		cbuf.Close()
+	}
+	if n != 2 || lerr != errBufferClosed {
+		t.Fatalf("n: %d, err: %v", n, lerr)
+	}
+	for _, err := range XmlMsgsFromFileSeq(filepath.Join(t.TempDir(), "none.xml")) {
+		if !errors.Is(err, os.ErrNotExist) {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestQuerySeq(t *testing.T) {
+	m, err := DocToMap(metricsDoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var vals []interface{}
+	for v := range ValuesForKeySeq(m, "Value") {
+		vals = append(vals, v)
+	}
+	if len(vals) != 2 || len(ValuesForKey(m, "Value")) != 2 {
+		t.Fatalf("%v", vals)
+	}
+	vals = nil
+	for v := range ValuesFromKeyPathSeq(m, "Metrics.Metric.*", true) {
+		vals = append(vals, v)
+	}
+	if len(vals) != len(ValuesFromKeyPath(m, "Metrics.Metric.*", true)) {
+		t.Fatalf("%v", vals)
+	}
+	vals = nil
+	for v := range ValuesFromKeyPathSeq(m, "Metrics.Metric") {
+		vals = append(vals, v)
+		break
+	}
+	if len(vals) != 1 || vals[0].(map[string]interface{})["-id"] != "NORM" {
+		t.Fatalf("%v", vals)
+	}
+
+	var paths []string
+	for p := range PathsForKeySeq(m, "Metric") {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	if !reflect.DeepEqual(paths, []string{"Metrics.Metric", "Metrics.Other.Metric"}) {
+		t.Fatal(paths)
+	}
+	want := PathsForKey(m, "Metric")
+	sort.Strings(want)
+	if !reflect.DeepEqual(paths, want) {
+		t.Fatal(want)
+	}
+
+	// nested keys
+	m = map[string]interface{}{"doc": map[string]interface{}{"a": map[string]interface{}{"b": map[string]interface{}{"a": "x"}}}}
+	paths = PathsForKey(m, "a")
+	sort.Strings(paths)
+	if !reflect.DeepEqual(paths, []string{"doc.a", "doc.a.b.a"}) {
+		t.Fatal(paths)
+	}
+}
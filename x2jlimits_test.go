@@ -0,0 +1,149 @@
+package x2j
+
+import (
+	"bytes"
+	"errors"
+	"runtime/debug"
+	"strings"
+	"testing"
+)
+
+func TestLimits(t *testing.T) {
+	doc := `<doc a="1" b="2"><list><item>one</item><item>two</item><item>three</item></list><text>some longer text</text></doc>`
+
+	for _, v := range []struct {
+		limits Limits
+		target error
+	}{
+		{Limits{MaxDepth: 2}, ErrMaxDepth},
+		{Limits{MaxBytes: 64}, ErrMaxBytes},
+		{Limits{MaxElements: 4}, ErrMaxElements},
+		{Limits{MaxAttrs: 1}, ErrMaxAttrs},
+		{Limits{MaxText: 10}, ErrMaxText},
+	} {
+		d := NewDecoder()
+		d.Limits = v.limits
+		_, err := d.DocToMap(doc)
+		_, terr := d.ToMap(bytes.NewBufferString(doc))
+		for _, e := range []error{err, terr} {
+			if !errors.Is(e, v.target) {
+				t.Fatalf("%+v: %v", v.limits, e)
+			}
+			var le *LimitError
+			if !errors.As(e, &le) || le.Limit != v.target.(*LimitError).Limit {
+				t.Fatalf("%+v: %#v", v.limits, e)
+			}
+			for _, other := range []error{ErrMaxDepth, ErrMaxBytes, ErrMaxElements, ErrMaxAttrs, ErrMaxText} {
+				if other != v.target && errors.Is(e, other) {
+					t.Fatalf("%+v: %v is %v", v.limits, e, other)
+				}
+			}
+		}
+	}
+
+	// all within limits
+	d := NewDecoder()
+	d.Limits = Limits{MaxDepth: 3, MaxBytes: int64(len(doc)), MaxElements: 6, MaxAttrs: 2, MaxText: 16}
+	if _, err := d.DocToMap(doc); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.ToMap(bytes.NewBufferString(doc)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLimitsMsgs(t *testing.T) {
+	// MaxElements is per message
+	msgs := `<msg><a>1</a></msg><msg><a>2</a></msg><msg><a>3</a><b>4</b></msg>`
+	d := NewDecoder()
+	d.Limits.MaxElements = 2
+	var n int
+	var lerr error
+	err := d.XmlMsgsFromReader(bytes.NewBufferString(msgs),
+		func(m map[string]interface{}) bool { n++; return true },
+		func(err error) bool { lerr = err; return false })
+	if n != 2 || !errors.Is(lerr, ErrMaxElements) || err != lerr {
+		t.Fatalf("n: %d, err: %v, %v", n, lerr, err)
+	}
+}
+
+func TestDeepNesting(t *testing.T) {
+	const depth = 200000
+	doc := strings.Repeat("<a>", depth) + "x" + strings.Repeat("</a>", depth)
+
+	d := NewDecoder()
+	m, err := d.DocToMap(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// reflect.DeepEqual() would overflow the stack
+	nested := func(m map[string]interface{}) bool {
+		var i int
+		var v interface{} = m
+		for ; i < depth; i++ {
+			mm, ok := v.(map[string]interface{})
+			if !ok || len(mm) != 1 {
+				break
+			}
+			v = mm["a"]
+		}
+		return i == depth && v == "x"
+	}
+	if !nested(m) {
+		t.Fatal("DocToMap")
+	}
+
+	// nor do the tree and bulk decoders
+	defer debug.SetMaxStack(debug.SetMaxStack(16 << 20))
+	if mm, err := d.ToMap(strings.NewReader(doc)); err != nil || !nested(mm) {
+		t.Fatal("ToMap:", err)
+	}
+	var msgs int
+	err = d.XmlMsgsFromReader(strings.NewReader(doc+doc), func(mm map[string]interface{}) bool {
+		if msgs++; !nested(mm) {
+			t.Fatal("XmlMsgsFromReader: value")
+		}
+		return true
+	}, nil)
+	if err != nil || msgs != 2 {
+		t.Fatal("XmlMsgsFromReader:", msgs, err)
+	}
+	n, err := d.ToTree(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err = n.WriteXml(&buf, XmlOptions{}); err != nil || buf.String() != doc {
+		t.Fatal("WriteXml:", err)
+	}
+
+	d.Limits.MaxDepth = 1000
+	if _, err = d.DocToMap(doc); !errors.Is(err, ErrMaxDepth) {
+		t.Fatal(err)
+	}
+	if _, err = d.ToTree(strings.NewReader(doc)); !errors.Is(err, ErrMaxDepth) {
+		t.Fatal(err)
+	}
+}
+
+// nor do the map queries
+func TestDeepMap(t *testing.T) {
+	const depth = 5000
+	var v interface{} = map[string]interface{}{"b": []interface{}{"x", "y"}}
+	for i := 0; i < depth; i++ {
+		v = map[string]interface{}{"a": v}
+	}
+	m := v.(map[string]interface{})
+	path := strings.Repeat("a.", depth) + "b"
+
+	defer debug.SetMaxStack(debug.SetMaxStack(256 << 10))
+	if p := ListPaths(m); len(p) != 1 || p[0] != path {
+		t.Fatal("ListPaths")
+	}
+	if p := PathsForKey(m, "b"); len(p) != 1 || p[0] != path {
+		t.Fatal("PathsForKey")
+	}
+	if s := WriteMap(m); !strings.HasSuffix(s, strings.Repeat("  ", depth+1)+"[item: 1]\n"+strings.Repeat("  ", depth+1)+"[string] y") {
+		t.Fatal("WriteMap")
+	}
+}
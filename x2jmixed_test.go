@@ -0,0 +1,108 @@
+package x2j
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+var mixedDoc = `<doc><p class="intro">Hello <b>big</b> world, <i>again</i> and <b>again</b>.</p></doc>`
+
+func TestMixedSequence(t *testing.T) {
+	d := NewDecoder()
+	d.Mixed = MixedSequence
+	want := `{"doc":{"p":{"#seq":["Hello",{"b":"big"},"world,",{"i":"again"},"and",{"b":"again"},"."],"-class":"intro"}}}`
+
+	m, err := d.DocToMap(mixedDoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := json.Marshal(m); string(b) != want {
+		t.Fatalf("DocToMap: %s", string(b))
+	}
+
+	m, err = d.ToMap(bytes.NewBufferString(mixedDoc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := json.Marshal(m); string(b) != want {
+		t.Fatalf("ToMap: %s", string(b))
+	}
+
+	// annotations are in sequence, too
+	d.Annotations = AnnotateComments
+	doc := `<p>see<!--note--><a>x</a></p>`
+	want = `{"p":{"#seq":["see",{"#comment":"note"},{"a":"x"}]}}`
+	m, err = d.DocToMap(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := json.Marshal(m); string(b) != want {
+		t.Fatalf("DocToMap w/ comment: %s", string(b))
+	}
+	m, err = d.ToMap(bytes.NewBufferString(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := json.Marshal(m); string(b) != want {
+		t.Fatalf("ToMap w/ comment: %s", string(b))
+	}
+}
+
+func TestMixedConcat(t *testing.T) {
+	d := NewDecoder()
+	d.Mixed = MixedConcat
+	want := `{"doc":{"p":{"#text":"Hello world, and .","-class":"intro","b":["big","again"],"i":"again"}}}`
+
+	for _, f := range []func() (map[string]interface{}, error){
+		func() (map[string]interface{}, error) { return d.DocToMap(mixedDoc) },
+		func() (map[string]interface{}, error) { return d.ToMap(bytes.NewBufferString(mixedDoc)) },
+	} {
+		m, err := f()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b, _ := json.Marshal(m); string(b) != want {
+			t.Fatalf("got: %s", string(b))
+		}
+	}
+
+	// text only separated by a discarded comment
+	m, err := d.DocToMap(`<doc>one<!-- skip -->two</doc>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m["doc"] != "one two" {
+		t.Fatalf("doc: %v", m["doc"])
+	}
+}
+
+// adjacent character data isn't separated; text that a sub-element or comment came between is
+func TestMixedCharData(t *testing.T) {
+	tests := []struct {
+		mode      MixedMode
+		doc, want string
+	}{
+		{MixedConcat, `<a>t<![CDATA[(cd)]]>u</a>`, `{"a":"t(cd)u"}`},
+		{MixedConcat, `<a>x<!--c-->y<b/>z</a>`, `{"a":{"#text":"x y z","b":""}}`},
+		{MixedConcat, `<a k="v">t<![CDATA[u]]><b/>v<![CDATA[w]]></a>`, `{"a":{"#text":"tu vw","-k":"v","b":""}}`},
+		{MixedSequence, `<a>t<![CDATA[u]]><b/>v<!--c-->w</a>`, `{"a":{"#seq":["tu",{"b":""},"v w"]}}`},
+	}
+	for _, test := range tests {
+		d := NewDecoder()
+		d.Mixed = test.mode
+		m, err := d.DocToMap(test.doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := d.DocToTree(test.doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, v := range []map[string]interface{}{m, n.ToMap(d)} {
+			if b, _ := json.Marshal(v); string(b) != test.want {
+				t.Fatalf("%s: %s", test.doc, b)
+			}
+		}
+	}
+}
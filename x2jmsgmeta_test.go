@@ -0,0 +1,99 @@
+package x2j
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMsgsWithMeta(t *testing.T) {
+	msgs := pipeMsgs(20)
+	bad := strings.Replace(msgs, "<seq>7</seq>", "<seq>7</sq>", 1)
+	for _, workers := range []int{0, 3} {
+		d := NewDecoder()
+		d.Recast = true
+		d.Pipeline.Workers = workers
+		var next int
+		end := int64(-1) // of the last message; they are separated by newlines
+		err := d.XmlMsgsFromReaderWithMeta(strings.NewReader(bad),
+			func(msg *XmlMsg) bool {
+				if msg.Seq != next || msgSeq(msg.Map) != msg.Seq || bad[msg.Start:msg.End] != string(msg.Raw) || msg.Start != end+1 {
+					t.Fatalf("workers: %d, %d: %d-%d, %q", workers, msg.Seq, msg.Start, msg.End, msg.Raw)
+				}
+				next, end = msg.Seq+1, msg.End
+				return true
+			},
+			func(err error) bool {
+				var be *BadMsgError
+				if !errors.As(err, &be) || be.Seq != 7 || be.Seq != next || be.Start != end+1 {
+					t.Fatal(err)
+				}
+				// its raw bytes and the newline
+				next, end = be.Seq+1, be.End-1
+				return true
+			})
+		if err != nil || next != 20 || end != int64(len(bad)-1) {
+			t.Fatalf("workers: %d, next: %d, end: %d, err: %v", workers, next, end, err)
+		}
+	}
+}
+
+func TestMsgsFromFileAt(t *testing.T) {
+	msgs := pipeMsgs(20)
+	dir := t.TempDir()
+	for name, data := range map[string][]byte{
+		"msgs.xml":    []byte(msgs),
+		"msgs.xml.gz": gzipped(t, msgs),
+	} {
+		fname := filepath.Join(dir, name)
+		if err := os.WriteFile(fname, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		for _, workers := range []int{0, 2} {
+			d := NewDecoder()
+			d.Recast = true
+			d.Pipeline.Workers = workers
+
+			// stop after the 5th message, then resume from the checkpoint
+			var off int64
+			var seq int
+			var got []int
+			err := d.XmlMsgsFromFileWithMeta(fname,
+				func(msg *XmlMsg) bool {
+					got = append(got, msgSeq(msg.Map))
+					off, seq = msg.End, msg.Seq+1
+					return len(got) < 5
+				},
+				func(err error) bool { t.Fatal(err); return false })
+			if err != nil || seq != 5 {
+				t.Fatalf("%s: %v, %v", name, got, err)
+			}
+			err = d.XmlMsgsFromFileAt(fname, off, seq,
+				func(msg *XmlMsg) bool {
+					if msg.Seq != msgSeq(msg.Map) || msgs[msg.Start:msg.End] != string(msg.Raw) {
+						t.Fatalf("%s: %d: %d-%d", name, msg.Seq, msg.Start, msg.End)
+					}
+					got = append(got, msg.Seq)
+					return true
+				},
+				func(err error) bool { t.Fatal(err); return false })
+			if err != nil || len(got) != 20 || got[19] != 19 {
+				t.Fatalf("%s: %v, %v", name, got, err)
+			}
+
+			// at the end, nothing's left
+			if err = d.XmlMsgsFromFileAt(fname, int64(len(msgs)), 20,
+				func(msg *XmlMsg) bool { t.Fatal(msg.Seq); return false },
+				func(err error) bool { t.Fatal(err); return false }); err != nil {
+				t.Fatal(err)
+			}
+			if err = d.XmlMsgsFromFileAt(fname, int64(len(msgs))+1, 20,
+				func(msg *XmlMsg) bool { return true },
+				func(err error) bool { return true }); err == nil {
+				t.Fatal(name)
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package x2j
+
+import (
+	"testing"
+)
+
+var nsDoc = `<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/">
+<s:Body>
+<GetClaimStatusCodesResponse xmlns="http://tempuri.org/">
+<GetClaimStatusCodesResult xmlns:a="http://schemas.datacontract.org/2004/07/MRA.Claim.WebService.Domain">
+<a:ClaimStatusCodeRecord>
+<a:Code>A</a:Code>
+<Code>default</Code>
+</a:ClaimStatusCodeRecord>
+</GetClaimStatusCodesResult>
+</GetClaimStatusCodesResponse>
+</s:Body>
+</s:Envelope>`
+
+const (
+	soapURI = "http://schemas.xmlsoap.org/soap/envelope/"
+	recURI  = "http://schemas.datacontract.org/2004/07/MRA.Claim.WebService.Domain"
+)
+
+func TestNamespaceLocal(t *testing.T) {
+	m, err := DocToMap(nsDoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the two Code elements collide and are decoded as a list
+	v, err := MapValue(m, "Envelope.Body.GetClaimStatusCodesResponse.GetClaimStatusCodesResult.ClaimStatusCodeRecord.Code", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l, ok := v.([]interface{}); !ok || len(l) != 2 {
+		t.Fatalf("NSLocal: %v", v)
+	}
+	// a prefixed path still matches the local names
+	if vv := ValuesFromKeyPath(m, "s:Envelope.s:Body.*.*.a:ClaimStatusCodeRecord.Code"); len(vv) != 2 {
+		t.Fatalf("NSLocal prefixed path: %v", vv)
+	}
+}
+
+func TestNamespacePrefix(t *testing.T) {
+	d := NewDecoder()
+	d.Namespace = NSPrefix
+	m, err := d.DocToMap(nsDoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, ok := m["s:Envelope"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("no s:Envelope key: %v", m)
+	}
+	if env["-xmlns:s"] != soapURI {
+		t.Fatalf("-xmlns:s: %v", env["-xmlns:s"])
+	}
+	rec := ValuesFromKeyPath(m, "s:Envelope.s:Body.GetClaimStatusCodesResponse.GetClaimStatusCodesResult.a:ClaimStatusCodeRecord")
+	if len(rec) != 1 {
+		t.Fatalf("rec: %v", rec)
+	}
+	r := rec[0].(map[string]interface{})
+	if r["a:Code"] != "A" || r["Code"] != "default" {
+		t.Fatalf("rec: %v", r)
+	}
+
+	// match on any form
+	paths := map[string]string{
+		"Envelope.Body.*.*.ClaimStatusCodeRecord.a:Code":                     "A",
+		"{" + soapURI + "}Envelope.Body.*.*.*.{" + recURI + "}Code":          "A",
+		"s:Envelope.s:Body.*.*.ClaimStatusCodeRecord.Code":                   "default",
+		"Envelope.Body.GetClaimStatusCodesResponse.*.*.{" + recURI + "}Code": "A",
+	}
+	for path, want := range paths {
+		v := ValuesFromKeyPath(m, path)
+		if len(v) != 1 || v[0] != want {
+			t.Errorf("path %s: %v", path, v)
+		}
+	}
+
+	v, err := MapValue(m, "{"+soapURI+"}Envelope.Body", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := v.(map[string]interface{})["GetClaimStatusCodesResponse"]; !ok {
+		t.Fatalf("MapValue: %v", v)
+	}
+}
+
+func TestNamespaceExpanded(t *testing.T) {
+	d := NewDecoder()
+	d.Namespace = NSExpanded
+	n, err := d.DocToTree(nsDoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.key != "{"+soapURI+"}Envelope" {
+		t.Fatalf("root key: %s", n.key)
+	}
+
+	m, err := d.DocToMap(nsDoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := ValuesFromKeyPath(m, "s:Envelope.s:Body.*.*.a:ClaimStatusCodeRecord.a:Code")
+	if len(v) != 1 || v[0] != "A" {
+		t.Fatalf("prefix path: %v", v)
+	}
+	v = ValuesFromKeyPath(m, "Envelope.Body.GetClaimStatusCodesResponse.*.*.{http://tempuri.org/}Code")
+	if len(v) != 1 || v[0] != "default" {
+		t.Fatalf("expanded path: %v", v)
+	}
+}
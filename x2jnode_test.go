@@ -0,0 +1,87 @@
+package x2j
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNodeNavigation(t *testing.T) {
+	doc := `<?xml-stylesheet href="s.xsl"?>
+<books>
+	<book id="1" lang="en"><title>Go</title><!-- first --></book>
+	<book id="2"><title>XML</title><author>X</author></book>
+	<magazine>M</magazine>
+</books>`
+	d := NewDecoder()
+	d.Annotations = AnnotateComments | AnnotateProcInst
+	n, err := d.DocToTree(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Name() != "books" || n.Parent() != nil || len(n.Prolog()) != 1 || n.Prolog()[0].Value() != `xml-stylesheet href="s.xsl"` {
+		t.Fatalf("%s: %v", n.Name(), n.Prolog())
+	}
+
+	var names []string
+	for _, c := range n.Children() {
+		names = append(names, c.Name())
+		if c.Parent() != n {
+			t.Fatal(c.Name())
+		}
+	}
+	if !reflect.DeepEqual(names, []string{"book", "book", "magazine"}) {
+		t.Fatal(names)
+	}
+
+	books := n.ChildrenNamed("book")
+	if len(books) != 2 || books[0] != n.FirstChild("book") || !books[0].IsListMember() || n.FirstChild("magazine").IsListMember() {
+		t.Fatal(books)
+	}
+	if n.FirstChild("none") != nil || n.ChildrenNamed("none") != nil {
+		t.Fatal("none")
+	}
+	if n.FirstChild("magazine").Value() != "M" {
+		t.Fatal(n.FirstChild("magazine").Value())
+	}
+
+	b := books[0]
+	attrs := b.Attrs()
+	if len(attrs) != 2 || attrs[0].Name() != "id" || attrs[1].Value() != "en" || !attrs[0].IsAttr() || attrs[0].Parent() != b {
+		t.Fatal(attrs)
+	}
+	if id, ok := b.Attr("id"); !ok || id != "1" {
+		t.Fatal(id)
+	}
+	if _, ok := b.Attr("none"); ok {
+		t.Fatal("none")
+	}
+	c := b.Children()
+	if len(c) != 2 || c[0].Value() != "Go" || c[1].Kind() != CommentNode || c[1].Value() != " first " || c[1].Parent() != b {
+		t.Fatal(c)
+	}
+	if a, _ := books[1].FirstChild("title").Parent().Attr("id"); a != "2" {
+		t.Fatal(a)
+	}
+}
+
+func TestNodeMixed(t *testing.T) {
+	d := NewDecoder()
+	d.Mixed = MixedSequence
+	d.AttrPrefix = "@"
+	n, err := d.DocToTree(`<p class="x">one <b>two</b> three</p>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := n.Attr("class"); v != "x" || n.Attrs()[0].Name() != "class" {
+		t.Fatal(v)
+	}
+	var kinds []NodeKind
+	var vals []string
+	for _, c := range n.Children() {
+		kinds = append(kinds, c.Kind())
+		vals = append(vals, c.Value())
+	}
+	if !reflect.DeepEqual(kinds, []NodeKind{TextNode, ElementNode, TextNode}) || !reflect.DeepEqual(vals, []string{"one", "two", "three"}) {
+		t.Fatal(kinds, vals)
+	}
+}
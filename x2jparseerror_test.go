@@ -0,0 +1,88 @@
+package x2j
+
+import (
+	"encoding/xml"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseError(t *testing.T) {
+	doc := "<doc>\n  <list>\n    <item>one</item>\n    <item>two</list>\n</doc>"
+
+	d := NewDecoder()
+	_, err := d.DocToMap(doc)
+	_, terr := d.ToTree(strings.NewReader(doc))
+	for _, e := range []error{err, terr} {
+		var pe *ParseError
+		if !errors.As(e, &pe) {
+			t.Fatalf("%#v", e)
+		}
+		if pe.Line != 4 || pe.Column != 21 || pe.Offset != 56 {
+			t.Fatalf("position: %d, %d, %d", pe.Line, pe.Column, pe.Offset)
+		}
+		if !reflect.DeepEqual(pe.Path, []string{"doc", "list", "item"}) {
+			t.Fatalf("path: %v", pe.Path)
+		}
+		var se *xml.SyntaxError
+		if !errors.As(e, &se) {
+			t.Fatalf("%#v", pe.Err)
+		}
+		if !strings.HasPrefix(e.Error(), "line 4, column 21, offset 56, in doc.list.item: ") {
+			t.Fatal(e.Error())
+		}
+	}
+
+	// the other errors are wrapped, too
+	d.Limits.MaxDepth = 2
+	_, err = d.DocToMap(doc)
+	var pe *ParseError
+	if !errors.As(err, &pe) || !errors.Is(err, ErrMaxDepth) {
+		t.Fatalf("%#v", err)
+	}
+	if !reflect.DeepEqual(pe.Path, []string{"doc", "list"}) {
+		t.Fatalf("path: %v", pe.Path)
+	}
+}
+
+func TestParseErrorMsgs(t *testing.T) {
+	msgs := "<msg><a>1</a></msg>\n<msg><a>2</a></msg>\n<msg>\n<a>3</b>\n</msg>"
+	check := func(err error) {
+		var pe *ParseError
+		if !errors.As(err, &pe) {
+			t.Fatalf("%#v", err)
+		}
+		if pe.Line != 4 || pe.Column != 9 || pe.Offset != int64(strings.Index(msgs, "</b>")+4) {
+			t.Fatalf("position: %d, %d, %d", pe.Line, pe.Column, pe.Offset)
+		}
+		if !reflect.DeepEqual(pe.Path, []string{"msg", "a"}) {
+			t.Fatalf("path: %v", pe.Path)
+		}
+	}
+
+	buf := NewXmlBuffer(msgs)
+	defer buf.Close()
+	for i := 0; i < 2; i++ {
+		if _, err := buf.NextMap(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := buf.NextMap()
+	check(err)
+
+	fname := filepath.Join(t.TempDir(), "msgs.xml")
+	if err := os.WriteFile(fname, []byte(msgs), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = XmlMsgsFromFile(fname,
+		func(m map[string]interface{}) bool { return true },
+		func(err error) bool { return false })
+	check(err)
+	err = XmlMsgsFromFileAsJson(fname,
+		func(s string) bool { return true },
+		func(err error) bool { return false })
+	check(err)
+}
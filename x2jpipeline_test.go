@@ -0,0 +1,188 @@
+package x2j
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// pipeMsgs - 'n' messages; <msg><seq>i</seq>...</msg>, of varying sizes.
+func pipeMsgs(n int) string {
+	var s string
+	for i := 0; i < n; i++ {
+		s += "<msg><seq>" + strconv.Itoa(i) + "</seq>" + strings.Repeat("<x>y</x>", i%7*50) + "</msg>\n"
+	}
+	return s
+}
+
+func msgSeq(m map[string]interface{}) int {
+	return int(m["msg"].(map[string]interface{})["seq"].(float64))
+}
+
+func TestPipeline(t *testing.T) {
+	const n = 100
+	msgs := pipeMsgs(n)
+	fname := filepath.Join(t.TempDir(), "msgs.xml")
+	if err := os.WriteFile(fname, []byte(msgs), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, unordered := range []bool{false, true} {
+		d := NewDecoder()
+		d.Recast = true
+		d.Pipeline = Pipeline{Workers: 4, Unordered: unordered}
+
+		for _, run := range []func(func(map[string]interface{}) bool) error{
+			func(ph func(map[string]interface{}) bool) error {
+				return d.XmlMsgsFromReader(bytes.NewBufferString(msgs), ph, func(err error) bool { t.Fatal(err); return false })
+			},
+			func(ph func(map[string]interface{}) bool) error {
+				return d.XmlMsgsFromFile(fname, ph, func(err error) bool { t.Fatal(err); return false })
+			},
+			func(ph func(map[string]interface{}) bool) error {
+				return d.XmlMsgsFromReaderAsJson(strings.NewReader(msgs), func(s string) bool {
+					var m map[string]interface{}
+					if err := json.Unmarshal([]byte(s), &m); err != nil {
+						t.Fatal(err)
+					}
+					return ph(m)
+				}, func(err error) bool { t.Fatal(err); return false })
+			},
+		} {
+			seen := make(map[int]bool)
+			var last = -1
+			err := run(func(m map[string]interface{}) bool {
+				i := msgSeq(m)
+				if !unordered && i != last+1 {
+					t.Fatalf("got %d after %d", i, last)
+				}
+				last = i
+				seen[i] = true
+				return true
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(seen) != n {
+				t.Fatalf("unordered: %v, seen: %d", unordered, len(seen))
+			}
+		}
+	}
+}
+
+func TestPipelineErrors(t *testing.T) {
+	msgs := pipeMsgs(20)
+	bad := strings.Replace(msgs, "<seq>7</seq>", "<seq>7</sq>", 1)
+	d := NewDecoder()
+	d.Recast = true
+	d.Pipeline = Pipeline{Workers: 3}
+
+	// ehandler stops processing
+	var handled []int
+	var errs []error
+	err := d.XmlMsgsFromReader(strings.NewReader(bad),
+		func(m map[string]interface{}) bool { handled = append(handled, msgSeq(m)); return true },
+		func(err error) bool { errs = append(errs, err); return false })
+	var pe *ParseError
+	if len(handled) != 7 || len(errs) != 1 || err != errs[0] || !errors.As(err, &pe) {
+		t.Fatalf("handled: %v, errs: %v, err: %v", handled, errs, err)
+	}
+
+	// or not - as for the serial reader, the rest of the bad message is skipped
+	serial := *d
+	serial.Pipeline = Pipeline{}
+	for _, dd := range []*Decoder{d, &serial} {
+		handled, errs = nil, nil
+		err = dd.XmlMsgsFromReader(strings.NewReader(bad),
+			func(m map[string]interface{}) bool { handled = append(handled, msgSeq(m)); return true },
+			func(err error) bool { errs = append(errs, err); return true })
+		if err != nil || len(handled) != 19 || len(errs) != 1 {
+			t.Fatalf("handled: %v, errs: %v, err: %v", handled, errs, err)
+		}
+	}
+
+	// the position in a file
+	fname := filepath.Join(t.TempDir(), "msgs.xml")
+	if err := os.WriteFile(fname, []byte(bad), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = d.XmlMsgsFromFile(fname,
+		func(m map[string]interface{}) bool { return true },
+		func(err error) bool { return false })
+	if !errors.As(err, &pe) || pe.Line != 8 {
+		t.Fatalf("%v", err)
+	}
+
+	// phandler stops processing
+	handled = nil
+	err = d.XmlMsgsFromReader(strings.NewReader(msgs),
+		func(m map[string]interface{}) bool { handled = append(handled, msgSeq(m)); return len(handled) < 5 },
+		func(err error) bool { return false })
+	if err != nil || len(handled) != 5 {
+		t.Fatalf("handled: %v, err: %v", handled, err)
+	}
+
+	// canceled
+	ctx, cancel := context.WithCancel(context.Background())
+	handled = nil
+	err = d.XmlMsgsFromReaderContext(ctx, strings.NewReader(msgs),
+		func(m map[string]interface{}) bool {
+			if handled = append(handled, msgSeq(m)); len(handled) == 3 {
+				cancel()
+			}
+			return true
+		},
+		func(err error) bool { return true })
+	var ce *ContextError
+	if !errors.Is(err, context.Canceled) || !errors.As(err, &ce) || ce.Msgs != 3 || len(handled) != 3 {
+		t.Fatalf("handled: %v, err: %v", handled, err)
+	}
+}
+
+// countingReader - an io.ByteReader, so it's read no further than needed,
+// that counts the messages read.
+type countingReader struct {
+	r    *strings.Reader
+	msgs atomic.Int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+func (c *countingReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if b == '\n' {
+		c.msgs.Add(1)
+	}
+	return b, err
+}
+
+func TestPipelineWindow(t *testing.T) {
+	const n = 50
+	d := NewDecoder()
+	d.Recast = true
+	d.Pipeline = Pipeline{Workers: 2, Window: 3}
+	rdr := &countingReader{r: strings.NewReader(pipeMsgs(n))}
+	var handled int
+	err := d.XmlMsgsFromReader(rdr,
+		func(m map[string]interface{}) bool {
+			handled++
+			// this one and those in the window; the last '\n' is read with the next message
+			if read := int(rdr.msgs.Load()); read > handled+d.Pipeline.Window {
+				t.Fatalf("handled: %d, read: %d", handled, read)
+			}
+			return true
+		},
+		func(err error) bool { t.Fatal(err); return false })
+	if err != nil || handled != n {
+		t.Fatalf("handled: %d, err: %v", handled, err)
+	}
+}
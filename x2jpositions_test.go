@@ -0,0 +1,153 @@
+package x2j
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const posDoc = `<?xml version="1.0"?>
+<!--books-->
+<doc xmlns:x="urn:x">
+	<book id="1" x:lang = 'en'>
+		<title>Go</title>
+	</book>
+	<book
+	  id="2"><![CDATA[a<b]]><title>XML</title></book>
+	<empty a="&quot;>"   b="y"/>
+</doc>`
+
+// posAt - the position of the n'th 's' in 'doc'.
+func posAt(doc, s string, n int) Position {
+	off := 0
+	for ; n >= 0; n-- {
+		i := strings.Index(doc[off:], s)
+		if i < 0 {
+			return Position{}
+		}
+		off += i
+		if n > 0 {
+			off++
+		}
+	}
+	ps := startPos
+	ps.advance([]byte(doc[:off])...)
+	return ps
+}
+
+func TestNodePos(t *testing.T) {
+	for _, set := range []func(d *Decoder){
+		func(d *Decoder) {},
+		// more readers between the source and encoding/xml
+		func(d *Decoder) { d.Annotations, d.Limits.MaxBytes = AnnotateAll, 1000 },
+	} {
+		d := NewDecoder()
+		d.Positions = true
+		set(d)
+		n, err := d.DocToTree(posDoc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n.Pos() != posAt(posDoc, "<doc", 0) {
+			t.Fatal(n.Pos())
+		}
+		books := n.ChildrenNamed("book")
+		a := books[0].Attrs()
+		if books[0].Pos() != posAt(posDoc, "<book", 0) ||
+			a[0].Pos() != posAt(posDoc, "id=", 0) || a[1].Pos() != posAt(posDoc, "x:lang", 0) {
+			t.Fatal(books[0].Pos(), a[0].Pos(), a[1].Pos())
+		}
+		if p := books[1].Attrs()[0].Pos(); p != posAt(posDoc, "id=", 1) || p.Line != 8 || p.Column != 4 {
+			t.Fatal(p)
+		}
+		if p := books[1].FirstChild("title").Pos(); p != posAt(posDoc, "<title", 1) {
+			t.Fatal(p)
+		}
+		a = n.FirstChild("empty").Attrs()
+		if a[0].Pos() != posAt(posDoc, "a=", 0) || a[1].Pos() != posAt(posDoc, "b=", 0) {
+			t.Fatal(a[0].Pos(), a[1].Pos())
+		}
+		if d.Annotations != 0 {
+			if p := n.Prolog()[0].Pos(); p != posAt(posDoc, "<!--", 0) {
+				t.Fatal(p)
+			}
+			if c := books[1].Children()[0]; c.Kind() != CDATANode || c.Pos() != posAt(posDoc, "<![CDATA[", 0) {
+				t.Fatal(c.Pos())
+			}
+		}
+	}
+	if p := NewElement("x").Pos(); p != (Position{}) {
+		t.Fatal(p)
+	}
+	// only noted if asked for
+	n, _ := DocToTree(posDoc)
+	if p := n.FirstChild("empty").Attrs()[0].Pos(); n.Pos() != (Position{}) || p != (Position{}) {
+		t.Fatal(n.Pos(), p)
+	}
+}
+
+func TestMapPositions(t *testing.T) {
+	d := NewDecoder()
+	d.Positions = true
+	m, err := d.DocToMap(posDoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pm := m[PosKey].(map[string]Position)
+	want := map[string]Position{
+		"doc":               posAt(posDoc, "<doc", 0),
+		"doc.-x":            posAt(posDoc, "xmlns:x", 0),
+		"doc.book[0]":       posAt(posDoc, "<book", 0),
+		"doc.book[0].-id":   posAt(posDoc, "id=", 0),
+		"doc.book[1].-id":   posAt(posDoc, "id=", 1),
+		"doc.book[1].title": posAt(posDoc, "<title", 1),
+		"doc.empty.-b":      posAt(posDoc, "b=", 0),
+	}
+	for k, v := range want {
+		if pm[k] != v {
+			t.Errorf("%s: %v, not %v", k, pm[k], v)
+		}
+	}
+	if len(pm) != 12 {
+		t.Fatal(len(pm), pm)
+	}
+	// the values are as usual
+	delete(m, PosKey)
+	d.Positions = false
+	if mm, _ := d.DocToMap(posDoc); !reflect.DeepEqual(mm, m) {
+		t.Fatal(m)
+	}
+}
+
+func TestMsgPositions(t *testing.T) {
+	msgs := "<a>1</a>\n<a>\n  <b x=\"1\"/>\n</a>\n<a/>"
+	for _, workers := range []int{0, 2} {
+		d := NewDecoder()
+		d.Positions = true
+		d.Pipeline.Workers = workers
+		var got []map[string]Position
+		err := d.XmlMsgsFromReader(strings.NewReader(msgs), func(m map[string]interface{}) bool {
+			got = append(got, m[PosKey].(map[string]Position))
+			return true
+		}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 3 || got[1]["a"] != posAt(msgs, "<a", 1) ||
+			got[1]["a.b.-x"] != posAt(msgs, "x=", 0) || got[2]["a"] != posAt(msgs, "<a", 2) {
+			t.Fatal(workers, got)
+		}
+	}
+
+	d := NewDecoder()
+	d.Positions = true
+	buf := d.NewXmlBuffer(msgs)
+	buf.NextTree()
+	n, err := buf.NextTree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := n.FirstChild("b").Pos(); p != (Position{Line: 3, Column: 3, Offset: 15}) {
+		t.Fatal(p)
+	}
+}
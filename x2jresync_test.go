@@ -0,0 +1,129 @@
+package x2j
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// readMsgs - the 'msg' values read and the BadMsgErrors.
+func readMsgs(t *testing.T, d *Decoder, s string) ([]interface{}, []*BadMsgError) {
+	var got []interface{}
+	var bad []*BadMsgError
+	err := d.XmlMsgsFromReader(strings.NewReader(s),
+		func(m map[string]interface{}) bool {
+			for _, v := range m {
+				got = append(got, v)
+			}
+			return true
+		},
+		func(err error) bool {
+			var be *BadMsgError
+			var pe *ParseError
+			if !errors.As(err, &be) || !errors.As(err, &pe) {
+				t.Fatal(err)
+			}
+			bad = append(bad, be)
+			return true
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got, bad
+}
+
+func TestResync(t *testing.T) {
+	tests := []struct {
+		msgs, root string
+		want       []interface{}
+		bad        []string
+	}{
+		// no end tag; the next message was read as part of it
+		{"<msg><a>1</a>\n<msg>2</msg>\n<msg>3</msg>", "",
+			[]interface{}{"2", "3"}, []string{"<msg><a>1</a>\n"}},
+		// the rest of the message isn't read as messages
+		{"<msg><a>1</b><c>x</c></msg>\n<msg>2</msg>", "",
+			[]interface{}{"2"}, []string{"<msg><a>1</b><c>x</c></msg>\n"}},
+		{"<msg>1</msg><msg><a>2</a></c>", "",
+			[]interface{}{"1"}, []string{"<msg><a>2</a></c>"}},
+		// any prefix
+		{"<a:rec><b>1</c></a:rec>\n<a:rec>2</a:rec>", "",
+			[]interface{}{"2"}, []string{"<a:rec><b>1</c></a:rec>\n"}},
+		// the root element of the bad message or MsgRoot
+		{"<rec><x>1</y></rec>\n<other>junk</other>\n<rec>2</rec>", "",
+			[]interface{}{"2"}, []string{"<rec><x>1</y></rec>\n<other>junk</other>\n"}},
+		{"<rec><x>1</y></rec>\n<other>junk</other>\n<rec>2</rec>", "other",
+			[]interface{}{"junk", "2"}, []string{"<rec><x>1</y></rec>\n"}},
+		// an XML declaration
+		{"<msg>1</bad>\n<?xml version=\"1.0\"?><other>2</other>", "",
+			[]interface{}{"2"}, []string{"<msg>1</bad>\n"}},
+	}
+	for _, test := range tests {
+		for _, workers := range []int{0, 2} {
+			d := NewDecoder()
+			d.MsgRoot = test.root
+			d.Pipeline.Workers = workers
+			got, bad := readMsgs(t, d, test.msgs)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("%q: got %v", test.msgs, got)
+			}
+			var raw []string
+			for _, e := range bad {
+				raw = append(raw, string(e.Raw))
+				// for a dead-letter file
+				if test.msgs[e.Start:e.End] != string(e.Raw) {
+					t.Fatalf("%q: %d-%d: %q", test.msgs, e.Start, e.End, e.Raw)
+				}
+			}
+			if !reflect.DeepEqual(raw, test.bad) {
+				t.Fatalf("%q: bad %q", test.msgs, raw)
+			}
+		}
+	}
+}
+
+func TestResyncSplitter(t *testing.T) {
+	s := NewMsgSplitter(strings.NewReader("<msg>1</msg>\n<msg><a>2</b>\n<msg>3</msg>"))
+	var errs int
+	var got []string
+	for {
+		b, err := s.NextRaw()
+		if err != nil {
+			var be *BadMsgError
+			if !errors.As(err, &be) {
+				break
+			}
+			if be.Start != 13 || be.End != 27 {
+				t.Fatal(err)
+			}
+			errs++
+			continue
+		}
+		got = append(got, string(b))
+	}
+	if errs != 1 || !reflect.DeepEqual(got, []string{"<msg>1</msg>", "<msg>3</msg>"}) {
+		t.Fatalf("errs: %d, %q", errs, got)
+	}
+}
+
+func TestResyncMaxBytes(t *testing.T) {
+	big := "<msg>" + strings.Repeat("x", 100000) + "</msg>\n"
+	msgs := "<msg>1</msg>\n" + big + "<msg>2</msg>"
+	for _, workers := range []int{0, 2} {
+		d := NewDecoder()
+		d.Limits.MaxBytes = 64
+		d.Pipeline.Workers = workers
+		got, bad := readMsgs(t, d, msgs)
+		if !reflect.DeepEqual(got, []interface{}{"1", "2"}) || len(bad) != 1 {
+			t.Fatalf("got %v, %d bad", got, len(bad))
+		}
+		e := bad[0]
+		if e.Start != 13 || e.End != int64(13+len(big)) {
+			t.Fatalf("%d-%d", e.Start, e.End)
+		}
+		if len(e.Raw) != 64 || !strings.HasPrefix(big, string(e.Raw)) {
+			t.Fatalf("%d: %q", len(e.Raw), e.Raw)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package x2j
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// concatenated docs - each with a BOM or prolog - and junk between them
+var splitMsgs = "\xef\xbb\xbf<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<msg>1</msg>\n" +
+	"\xef\xbb\xbf<?xml version=\"1.0\"?>\n<!-- two --><msg>2</msg>" +
+	" junk & \xff more " +
+	"<?xml version=\"1.0\"?><!DOCTYPE msg><msg>3</msg>\ntrailing\n"
+
+func TestMsgSplitter(t *testing.T) {
+	s := NewMsgSplitter(strings.NewReader(splitMsgs), true)
+	var got []interface{}
+	var raw []string
+	for {
+		msg, err := s.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, msg.Map["msg"])
+		raw = append(raw, string(msg.Raw))
+	}
+	if !reflect.DeepEqual(got, []interface{}{float64(1), float64(2), float64(3)}) {
+		t.Fatalf("%v", got)
+	}
+	want := []string{
+		"<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<msg>1</msg>",
+		"<?xml version=\"1.0\"?>\n<!-- two --><msg>2</msg>",
+		"<?xml version=\"1.0\"?><!DOCTYPE msg><msg>3</msg>",
+	}
+	if !reflect.DeepEqual(raw, want) {
+		t.Fatalf("%q", raw)
+	}
+
+	// not decoded
+	s = NewMsgSplitter(bytes.NewBufferString(splitMsgs))
+	raw = nil
+	for {
+		b, err := s.NextRaw()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw = append(raw, string(b))
+	}
+	if !reflect.DeepEqual(raw, want) {
+		t.Fatalf("%q", raw)
+	}
+}
+
+func TestSplitBulkReaders(t *testing.T) {
+	want := []interface{}{"1", "2", "3"}
+	for _, workers := range []int{0, 2} {
+		d := NewDecoder()
+		d.Pipeline.Workers = workers
+		var got []interface{}
+		err := d.XmlMsgsFromReader(strings.NewReader(splitMsgs),
+			func(m map[string]interface{}) bool { got = append(got, m["msg"]); return true },
+			func(err error) bool { t.Fatal(err); return false })
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Fatalf("workers: %d, %v, %v", workers, got, err)
+		}
+	}
+
+	buf := NewXmlBuffer(splitMsgs)
+	defer buf.Close()
+	var got []interface{}
+	for m, err := range buf.MapSeq() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, m["msg"])
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("%v", got)
+	}
+
+	// positions are in the stream
+	buf = NewXmlBuffer("\xef\xbb\xbf<msg>1</msg> junk\n <msg><a>2</b></msg>")
+	defer buf.Close()
+	if _, err := buf.NextMap(); err != nil {
+		t.Fatal(err)
+	}
+	_, err := buf.NextMap()
+	var pe *ParseError
+	if !errors.As(err, &pe) || pe.Line != 2 || pe.Column != 15 || pe.Offset != 35 {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,83 @@
+package x2j
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+var metricsDoc = `<?xml version="1.0"?>
+<Metrics scope="app">
+	<Metric id="NORM" description="Number of Overridden Methods">
+		<Values>
+			<Value name="A" value="1"/>
+			<Value name="B" value="2"/>
+		</Values>
+	</Metric>
+	<Metric id="TLOC" description="Total Lines of Code">
+		<Value value="355100"/>
+	</Metric>
+	<Other><Metric id="X"/></Other>
+</Metrics>`
+
+func TestStreamValuesFromTagPaths(t *testing.T) {
+	m, err := DocToMap(metricsDoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []struct {
+		paths []string
+		want  []interface{}
+	}{
+		{[]string{"Metrics.Metric"}, ValuesFromKeyPath(m, "Metrics.Metric")},
+		{[]string{"Metric"}, append(ValuesFromKeyPath(m, "Metrics.Metric"), ValuesFromKeyPath(m, "Metrics.Other.Metric")...)},
+		{[]string{"*.*.Values"}, ValuesFromKeyPath(m, "Metrics.Metric.Values")},
+		{[]string{"Values", "Value"}, append(ValuesFromKeyPath(m, "Metrics.Metric.Values"), ValuesFromKeyPath(m, "Metrics.Metric.Value")...)},
+		{[]string{"Metrics"}, ValuesFromKeyPath(m, "Metrics")},
+		{[]string{"Nothing"}, nil},
+	} {
+		var got []interface{}
+		// not an io.ByteReader
+		err := StreamValuesFromTagPaths(iotest.OneByteReader(strings.NewReader(metricsDoc)), v.paths,
+			func(path string, val interface{}) bool {
+				got = append(got, val)
+				return true
+			})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, v.want) {
+			t.Fatalf("%v:\n%v\n%v", v.paths, got, v.want)
+		}
+	}
+
+	// the matching path is passed; stop early
+	var got []string
+	err = StreamValuesFromTagPaths(strings.NewReader(metricsDoc), []string{"Value", "Metric"},
+		func(path string, val interface{}) bool {
+			got = append(got, path)
+			return len(got) < 2
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, []string{"Metric", "Metric"}) {
+		t.Fatal(got)
+	}
+
+	// an error after a match
+	bad := strings.Replace(metricsDoc, "</Other>", "</Oth>", 1)
+	var n int
+	err = StreamValuesFromTagPaths(strings.NewReader(bad), []string{"Metrics.Metric"},
+		func(path string, val interface{}) bool {
+			n++
+			return true
+		})
+	var pe *ParseError
+	if n != 2 || !errors.As(err, &pe) || !reflect.DeepEqual(pe.Path, []string{"Metrics", "Other"}) {
+		t.Fatalf("n: %d, err: %v", n, err)
+	}
+}
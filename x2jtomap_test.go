@@ -0,0 +1,91 @@
+package x2j
+
+import (
+	"fmt"
+	"testing"
+)
+
+// the tree and map decoders agree
+func TestNodeToMap(t *testing.T) {
+	docs := []string{
+		`<a>x<b/>y</a>`,
+		`<a><b>1</b>y<c/>z</a>`,
+		`<a id="1">x<b/>y</a>`,
+		`<a><b>t</b><c>NaN</c><d>-Inf</d><e>1.5</e><f>true</f></a>`,
+		`<a x="t" y="NaN" z="2"/>`,
+		`<a>x<!--c-->y</a>`,
+		`<a id="1"><!--c-->x<!--d--></a>`,
+		`<a><b/><b/><c/></a>`,
+		`<a>p<![CDATA[x<y]]>q</a>`,
+		`<?xml version="1.0"?><!--top--><a/>`,
+		`<a>x<b>1</b>y<b>2</b>z</a>`,
+		`<a id="2">5<b>1</b>7</a>`,
+		`<a><i/><j> </j><i>2</i><j>true</j></a>`,
+	}
+	decoders := map[string]func(d *Decoder){
+		"default":  func(d *Decoder) {},
+		"recast":   func(d *Decoder) { d.Recast = true },
+		"naninf":   func(d *Decoder) { d.Recast, d.CastNanInf = true, true },
+		"concat":   func(d *Decoder) { d.Mixed = MixedConcat },
+		"sequence": func(d *Decoder) { d.Mixed, d.Annotations = MixedSequence, AnnotateAll },
+		"annotate": func(d *Decoder) { d.Annotations = AnnotateAll },
+		"preserve": func(d *Decoder) { d.Whitespace = WhitespacePreserve },
+		"force":    func(d *Decoder) { d.ForceList = []string{"a.b"} },
+		"types": func(d *Decoder) {
+			d.Recast = true
+			d.Types = map[string]TypeHint{"a": {Type: TypeString}, "i": {Type: TypeInt64}, "j": {Type: TypeBool}}
+		},
+		"types preserve": func(d *Decoder) {
+			d.Whitespace = WhitespacePreserve
+			d.Types = map[string]TypeHint{"i": {Type: TypeInt64}, "j": {Type: TypeBool}}
+		},
+	}
+	for name, set := range decoders {
+		for _, doc := range docs {
+			d := NewDecoder()
+			set(d)
+			m, err := d.DocToMap(doc)
+			if err != nil {
+				t.Fatal(name, doc, err)
+			}
+			n, err := d.DocToTree(doc)
+			if err != nil {
+				t.Fatal(name, doc, err)
+			}
+			// NaN != NaN, so compare as printed
+			if tm := n.ToMap(d); fmt.Sprint(tm) != fmt.Sprint(m) {
+				t.Errorf("%s: %s\nmap:  %v\ntree: %v", name, doc, m, tm)
+			}
+		}
+	}
+}
+
+func TestNodeToJson(t *testing.T) {
+	doc := `<!--c--><a><b>t</b><b>1</b><c>NaN</c></a>`
+	for _, recast := range []bool{false, true} {
+		n, err := DocToTree(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := NewDecoder()
+		d.Recast = recast
+		j, err := n.ToJson(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dj, _ := d.DocToJson(doc)
+		if j != dj {
+			t.Fatal(j, dj)
+		}
+	}
+
+	// the package-level defaults
+	n, _ := DocToTree(doc)
+	if m := n.ToMap(nil); fmt.Sprint(m) != "map[a:map[b:[t 1] c:NaN]]" {
+		t.Fatal(m)
+	}
+	// a sub-tree
+	if j, _ := n.ChildrenNamed("b")[1].ToJson(nil); j != `{"b":"1"}` {
+		t.Fatal(j)
+	}
+}
@@ -0,0 +1,104 @@
+package x2j
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+var typedDoc = `<order id="9223372036854775807">
+	<total>12.50</total>
+	<ref>18446744073709551615</ref>
+	<amount>12345678901234567890.01</amount>
+	<when>2016-05-01T10:00:00Z</when>
+	<day>01/05/2016</day>
+	<zip>02134</zip>
+	<gift>true</gift>
+</order>`
+
+func TestTypes(t *testing.T) {
+	d := NewDecoder()
+	d.Recast = true
+	d.Types = map[string]TypeHint{
+		"order.-id": {Type: TypeInt64},
+		"ref":       {Type: TypeUint64},
+		"amount":    {Type: TypeNumber},
+		"when":      {Type: TypeTime},
+		"day":       {Type: TypeTime, Layout: "02/01/2006"},
+		"zip":       {Type: TypeString},
+		"gift":      {Type: TypeBool},
+	}
+	m, err := d.DocToMap(typedDoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mm, err := d.ToMap(bytes.NewBufferString(typedDoc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []map[string]interface{}{m, mm} {
+		o := v["order"].(map[string]interface{})
+		if o["-id"] != int64(9223372036854775807) {
+			t.Errorf("-id: %#v", o["-id"])
+		}
+		if o["ref"] != uint64(18446744073709551615) {
+			t.Errorf("ref: %#v", o["ref"])
+		}
+		if o["amount"] != json.Number("12345678901234567890.01") {
+			t.Errorf("amount: %#v", o["amount"])
+		}
+		if w, ok := o["when"].(time.Time); !ok || !w.Equal(time.Date(2016, 5, 1, 10, 0, 0, 0, time.UTC)) {
+			t.Errorf("when: %#v", o["when"])
+		}
+		if w, ok := o["day"].(time.Time); !ok || w.Month() != time.May {
+			t.Errorf("day: %#v", o["day"])
+		}
+		// no hint - Recast applies
+		if o["total"] != 12.5 || o["zip"] != "02134" || o["gift"] != true {
+			t.Errorf("order: %v", o)
+		}
+	}
+}
+
+func TestTypesError(t *testing.T) {
+	d := NewDecoder()
+	d.Types = map[string]TypeHint{"order.zip": {Type: TypeInt64}, "-id": {Type: TypeBool}}
+	doc := `<order><zip>021x4</zip></order>`
+	for _, f := range []func() error{
+		func() error { _, err := d.DocToMap(doc); return err },
+		func() error { _, err := d.ToMap(bytes.NewBufferString(doc)); return err },
+	} {
+		var cerr *ConversionError
+		if err := f(); !errors.As(err, &cerr) || cerr.Path != "order.zip" || cerr.Type != TypeInt64 {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	// empty elements aren't decoded
+	if m, err := d.DocToMap(`<order><zip/></order>`); err != nil || m["order"].(map[string]interface{})["zip"] != "" {
+		t.Fatal(m, err)
+	}
+	if n, err := d.DocToTree(`<order><zip> </zip></order>`); err != nil || n.ToMap(d)["order"].(map[string]interface{})["zip"] != "" {
+		t.Fatal(err)
+	}
+
+	// not JSON numbers
+	d.Types["amount"] = TypeHint{Type: TypeNumber}
+	for _, v := range []string{"Inf", "+1", "1_0", "0x1F", "1.", "01", "true", `"1"`} {
+		_, err := d.DocToJson(`<order><amount>` + v + `</amount></order>`)
+		var cerr *ConversionError
+		if !errors.As(err, &cerr) || cerr.Path != "order.amount" || cerr.Type != TypeNumber {
+			t.Fatalf("%s: %v", v, err)
+		}
+	}
+	if j, err := d.DocToJson(`<order><amount> -1.5e300 </amount></order>`); err != nil || j != `{"order":{"amount":-1.5e300}}` {
+		t.Fatal(j, err)
+	}
+
+	_, err := d.DocToMap(`<order id="yes"/>`)
+	var cerr *ConversionError
+	if !errors.As(err, &cerr) || cerr.Path != "order.-id" {
+		t.Fatalf("attr err: %v", err)
+	}
+}
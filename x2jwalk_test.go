@@ -0,0 +1,117 @@
+package x2j
+
+import (
+	"strings"
+	"testing"
+)
+
+const walkDoc = `<!--c--><doc><book id="1"><title>Go</title></book><book id="2"><title>XML</title></book><n>3</n></doc>`
+
+func TestWalk(t *testing.T) {
+	d := NewDecoder()
+	d.Annotations = AnnotateComments
+	m, err := d.DocToMap(walkDoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var visits []string
+	Walk(m, func(path, key string, v interface{}, depth int, parent interface{}) WalkAction {
+		visits = append(visits, path+" "+key+" "+strings.Repeat(">", depth))
+		switch path {
+		case "doc.book[0].-id":
+			if parent.(map[string]interface{})["title"] != "Go" {
+				t.Fatal(parent)
+			}
+		case "doc.book[1]":
+			if _, ok := parent.([]interface{}); !ok {
+				t.Fatal(parent)
+			}
+		}
+		return WalkContinue
+	})
+	want := []string{
+		"#comment #comment ",
+		"doc doc ",
+		"doc.book book >",
+		"doc.book[0] book >",
+		"doc.book[0].-id -id >>",
+		"doc.book[0].title title >>",
+		"doc.book[1] book >",
+		"doc.book[1].-id -id >>",
+		"doc.book[1].title title >>",
+		"doc.n n >",
+	}
+	if strings.Join(visits, "\n") != strings.Join(want, "\n") {
+		t.Fatal(strings.Join(visits, "\n"))
+	}
+
+	// skip and stop
+	visits = visits[:0]
+	Walk(m, func(path, key string, v interface{}, depth int, parent interface{}) WalkAction {
+		visits = append(visits, path)
+		switch path {
+		case "doc.book":
+			return WalkSkip
+		case "doc.n":
+			return WalkStop
+		}
+		return WalkContinue
+	})
+	if strings.Join(visits, ",") != "#comment,doc,doc.book,doc.n" {
+		t.Fatal(visits)
+	}
+}
+
+func TestNodeWalk(t *testing.T) {
+	d := NewDecoder()
+	d.Annotations = AnnotateComments
+	n, err := d.DocToTree(walkDoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var visits []string
+	n.Walk(func(path string, v *Node, depth int) WalkAction {
+		visits = append(visits, path+" "+v.Value()+" "+strings.Repeat(">", depth))
+		return WalkContinue
+	})
+	want := []string{
+		"#comment c ",
+		"doc  ",
+		"doc.book[0]  >",
+		"doc.book[0].-id 1 >>",
+		"doc.book[0].title Go >>",
+		"doc.book[1]  >",
+		"doc.book[1].-id 2 >>",
+		"doc.book[1].title XML >>",
+		"doc.n 3 >",
+	}
+	if strings.Join(visits, "\n") != strings.Join(want, "\n") {
+		t.Fatal(strings.Join(visits, "\n"))
+	}
+
+	// the paths of elements and attributes are those of the map
+	m := n.ToMap(d)
+	Walk(m, func(path, key string, v interface{}, depth int, parent interface{}) WalkAction {
+		if _, ok := v.([]interface{}); !ok && !strings.Contains(strings.Join(want, "\n"), path+" ") {
+			t.Error(path)
+		}
+		return WalkContinue
+	})
+
+	// a sub-tree, skip and stop
+	visits = visits[:0]
+	book := n.ChildrenNamed("book")[1]
+	book.Walk(func(path string, v *Node, depth int) WalkAction {
+		visits = append(visits, path)
+		if v.IsAttr() {
+			return WalkSkip
+		}
+		if v.Name() == "title" {
+			return WalkStop
+		}
+		return WalkContinue
+	})
+	if strings.Join(visits, ",") != "book,book.-id,book.title" {
+		t.Fatal(visits)
+	}
+}
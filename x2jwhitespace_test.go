@@ -0,0 +1,81 @@
+package x2j
+
+import (
+	"bytes"
+	"testing"
+)
+
+var wsDoc = `<doc>
+	<code>  x := 1</code>
+	<note>  two
+	  lines </note>
+	<blank>   </blank>
+	<nbsp>x&#160;&#160;y&#160;</nbsp>
+	<pre xml:space="preserve">  kept  <in> also </in></pre>
+</doc>`
+
+func TestWhitespaceModes(t *testing.T) {
+	// non-breaking spaces aren't XML whitespace
+	const nbsp = "x\u00a0\u00a0y\u00a0"
+	cases := []struct {
+		mode     WhitespaceMode
+		xmlSpace bool
+		want     map[string]interface{}
+	}{
+		{WhitespaceTrim, false, map[string]interface{}{"code": "x := 1", "note": "two\n\t  lines", "blank": "", "in": "also", "nbsp": nbsp}},
+		{WhitespaceCollapse, false, map[string]interface{}{"code": "x := 1", "note": "two lines", "blank": "", "in": "also", "nbsp": nbsp}},
+		{WhitespacePreserve, false, map[string]interface{}{"code": "  x := 1", "note": "  two\n\t  lines ", "blank": "   ", "in": " also ", "nbsp": nbsp}},
+		{WhitespaceTrim, true, map[string]interface{}{"code": "x := 1", "note": "two\n\t  lines", "blank": "", "in": " also ", "nbsp": nbsp}},
+	}
+	for _, c := range cases {
+		d := NewDecoder()
+		d.Whitespace = c.mode
+		d.XmlSpace = c.xmlSpace
+		m, err := d.DocToMap(wsDoc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mm, err := d.ToMap(bytes.NewBufferString(wsDoc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, v := range []map[string]interface{}{m, mm} {
+			doc := v["doc"].(map[string]interface{})
+			for k, want := range c.want {
+				var got interface{}
+				if k == "in" {
+					got = doc["pre"].(map[string]interface{})["in"]
+				} else {
+					got = doc[k]
+				}
+				if got != want {
+					t.Errorf("mode %d, xmlSpace %v: %s: %q, want %q", c.mode, c.xmlSpace, k, got, want)
+				}
+			}
+		}
+	}
+}
+
+// text joined for MixedConcat isn't separated in the scope of xml:space="preserve"
+func TestXmlSpaceMixed(t *testing.T) {
+	doc := `<doc><a xml:space="preserve">x <b/> y</a><c>x <b/> y</c></doc>`
+	d := NewDecoder()
+	d.XmlSpace = true
+	d.Mixed = MixedConcat
+	m, err := d.DocToMap(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := d.DocToTree(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []map[string]interface{}{m, n.ToMap(d)} {
+		doc := v["doc"].(map[string]interface{})
+		a := doc["a"].(map[string]interface{})["#text"]
+		c := doc["c"].(map[string]interface{})["#text"]
+		if a != "x  y" || c != "x y" {
+			t.Fatalf("%q %q", a, c)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package x2j
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeXml(t *testing.T, n *Node, opts XmlOptions) string {
+	var b bytes.Buffer
+	if err := n.WriteXml(&b, opts); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func TestWriteXml(t *testing.T) {
+	n, err := DocToTree(`<doc a="1 &amp; 2"><x>v</x><empty></empty><x y="z">w &lt; 3</x></doc>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := writeXml(t, n, XmlOptions{}); s != `<doc a="1 &amp; 2"><x>v</x><empty></empty><x y="z">w &lt; 3</x></doc>` {
+		t.Fatal(s)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<doc a="1 &amp; 2">
+  <x>v</x>
+  <empty/>
+  <x y="z">w &lt; 3</x>
+</doc>
+`
+	if s := writeXml(t, n, XmlOptions{Declaration: true, Indent: "  ", SelfClose: true}); s != want {
+		t.Fatal(s)
+	}
+}
+
+// xmlText - the text of 'doc', without the whitespace-only segments.
+func xmlText(t *testing.T, doc string) string {
+	var text []string
+	p := xml.NewDecoder(strings.NewReader(doc))
+	for {
+		tok, err := p.Token()
+		if err == io.EOF {
+			return strings.Join(text, "|")
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c, ok := tok.(xml.CharData); ok && strings.TrimSpace(string(c)) != "" {
+			text = append(text, string(c))
+		}
+	}
+}
+
+func TestWriteXmlRoundTrip(t *testing.T) {
+	docs := []string{
+		doc1, doc2, metricsDoc,
+		`<?xml-stylesheet href="s.xsl"?><!-- c --><p class="x">one <b>two</b> three<![CDATA[<four>]]><!-- five --><?pi six?></p>`,
+		`<s:Envelope xmlns:s="urn:s" xmlns="urn:d"><s:Body><a s:attr="1">x</a><a>y</a></s:Body></s:Envelope>`,
+		`<p>Hello <b>big</b> world</p>`,
+	}
+	for _, doc := range docs {
+		for _, opts := range []XmlOptions{{}, {Indent: "\t", SelfClose: true}} {
+			d := NewDecoder()
+			d.Annotations = AnnotateAll
+			d.Mixed = MixedSequence
+			d.Namespace = NSPrefix
+			d.Whitespace = WhitespacePreserve
+			n, err := d.DocToTree(doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := writeXml(t, n, opts)
+			m1, err := d.DocToMap(doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m2, err := d.DocToMap(s)
+			if err != nil {
+				t.Fatal(s, err)
+			}
+			if !reflect.DeepEqual(m1, m2) {
+				t.Fatalf("%s\n%v\n%v", s, m1, m2)
+			}
+			if xmlText(t, s) != xmlText(t, doc) {
+				t.Fatalf("%s\n%q\n%q", s, xmlText(t, s), xmlText(t, doc))
+			}
+		}
+	}
+}
+
+// the text segments of an element without sub-elements, for MixedDefault
+func TestWriteXmlText(t *testing.T) {
+	docs := []string{
+		`<a>x<![CDATA[y]]></a>`,
+		`<a>1<!--c-->2</a>`,
+		`<a k="v">1<![CDATA[2]]>3</a>`,
+		`<a>x<![CDATA[y]]><b/>z</a>`,
+	}
+	for _, doc := range docs {
+		d := NewDecoder()
+		d.Namespace = NSPrefix
+		d.Whitespace = WhitespacePreserve
+		n, err := d.DocToTree(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := writeXml(t, n, XmlOptions{})
+		if got, want := strings.Replace(xmlText(t, s), "|", "", -1), strings.Replace(xmlText(t, doc), "|", "", -1); got != want {
+			t.Fatalf("%s: %s", doc, s)
+		}
+		// the last segment is the value, as in earlier releases
+		m, err := d.DocToMap(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mt := n.ToMap(d); !reflect.DeepEqual(mt, m) {
+			t.Fatalf("%s: %v %v", doc, mt, m)
+		}
+	}
+}
+
+// names are written as in the doc for any Decoder Namespace setting
+func TestWriteXmlNames(t *testing.T) {
+	docs := []struct {
+		doc, def string // def - as written when decoded by the default Decoder
+	}{
+		{`<s:E xmlns:s="u1"><s:B xml:lang="en">x</s:B></s:E>`,
+			`<s:E xmlns:s="u1"><s:B xml:lang="en">x</s:B></s:E>`},
+		{`<E xmlns="u1" xmlns:t="u2"><t:B t:a="1"><!-- c --><![CDATA[<x>]]></t:B><C/></E>`,
+			`<E xmlns="u1" xmlns:t="u2"><t:B t:a="1">&lt;x&gt;</t:B><C></C></E>`},
+		{`<a:E xmlns:a="u1"><b:E xmlns:b="u1" xmlns:a="u2" a:k="v">y</b:E></a:E>`,
+			`<a:E xmlns:a="u1"><b:E xmlns:b="u1" xmlns:a="u2" a:k="v">y</b:E></a:E>`},
+		{`<p>one<![CDATA[ two ]]><!--three--> four</p>`,
+			`<p>onetwofour</p>`},
+	}
+	for _, test := range docs {
+		for _, ns := range []NamespaceMode{NSLocal, NSPrefix, NSExpanded} {
+			d := NewDecoder()
+			d.Annotations = AnnotateAll
+			d.Namespace = ns
+			d.Whitespace = WhitespacePreserve
+			n, err := d.DocToTree(test.doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s := writeXml(t, n, XmlOptions{SelfClose: true}); s != test.doc {
+				t.Fatalf("%d: %s", ns, s)
+			}
+		}
+		n, err := DocToTree(test.doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s := writeXml(t, n, XmlOptions{}); s != test.def {
+			t.Fatal(s)
+		}
+	}
+}
+
+func TestNodeEdit(t *testing.T) {
+	d := NewDecoder()
+	d.ForceList = []string{"list.item"}
+	n, err := d.DocToTree(`<list><item id="1">a</item><other>b</other></list>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := n.FirstChild("item")
+	if !item.IsListMember() {
+		t.Fatal("forced")
+	}
+
+	// attributes
+	item.SetAttr("id", "one")
+	item.SetAttr("new", "x")
+	if !item.RemoveAttr("new") || item.RemoveAttr("new") {
+		t.Fatal("RemoveAttr")
+	}
+	item.SetAttr("lang", "en")
+	item.SetValue("A")
+
+	// children
+	other := n.FirstChild("other")
+	if !n.RemoveChild(other) || other.Parent() != nil || n.RemoveChild(other) {
+		t.Fatal("RemoveChild")
+	}
+	i2 := NewElement("item")
+	i2.SetValue("c")
+	if err = n.AppendChild(i2); err != nil {
+		t.Fatal(err)
+	}
+	if err = n.InsertChild(1, other); err != nil {
+		t.Fatal(err)
+	}
+	e := NewElement("e")
+	if err = n.InsertChild(0, e); err != nil {
+		t.Fatal(err)
+	}
+	if err = e.AppendChild(n); err == nil {
+		t.Fatal("cycle")
+	}
+	if err = n.InsertChild(5, NewElement("x")); err == nil {
+		t.Fatal("index")
+	}
+	if err = n.AppendChild(item.Attrs()[0]); err == nil {
+		t.Fatal("attribute")
+	}
+	// moved
+	if err = e.AppendChild(other); err != nil || other.Parent() != e {
+		t.Fatal(err)
+	}
+	want := `<list><e><other>b</other></e><item id="one" lang="en">A</item><item>c</item></list>`
+	if s := writeXml(t, n, XmlOptions{}); s != want {
+		t.Fatal(s)
+	}
+	if !item.IsListMember() || !i2.IsListMember() {
+		t.Fatal("list")
+	}
+	n.RemoveChild(i2)
+	// still forced
+	if !item.IsListMember() || i2.IsListMember() {
+		t.Fatal("list")
+	}
+	other.SetAttr("k", "v")
+	if a := other.Attrs(); len(a) != 1 || a[0].key != "-k" {
+		t.Fatal(a)
+	}
+	// the text of an element with attributes is kept as when decoded
+	if other.Value() != "b" || len(other.Children()) != 1 || other.Children()[0].kind != TextNode {
+		t.Fatal(other.Children())
+	}
+	other.RemoveAttr("k")
+	if other.val != "b" || len(other.nodes) != 0 {
+		t.Fatal(other.nodes)
+	}
+	if item.Value() != "A" {
+		t.Fatal(item.Value())
+	}
+
+	// mixed content
+	p := NewElement("p")
+	p.AppendChild(NewText("one & "))
+	p.AppendChild(NewElement("b"))
+	if s := writeXml(t, p, XmlOptions{Indent: " ", SelfClose: true}); strings.TrimSpace(s) != `<p>one &amp; <b/></p>` {
+		t.Fatal(s)
+	}
+
+	// new nodes are keyed as the tree was decoded
+	d = NewDecoder()
+	d.AttrPrefix, d.TextKey, d.Mixed = "@", "$t", MixedConcat
+	n, err = d.DocToTree(`<doc><a>x</a><b/></doc>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.FirstChild("a").SetAttr("id", "1")
+	b := n.FirstChild("b")
+	b.SetAttr("k", "v")
+	b.AppendChild(NewText("y"))
+	b.AppendChild(NewElement("c"))
+	b.AppendChild(NewText("z"))
+	// from a tree with the default keys
+	e = NewElement("e")
+	e.SetAttr("f", "g")
+	e.SetValue("h")
+	n.AppendChild(e)
+	m, _ := d.DocToMap(writeXml(t, n, XmlOptions{}))
+	if tm := n.ToMap(d); !reflect.DeepEqual(tm, m) {
+		t.Fatalf("%v\n%v", tm, m)
+	}
+	if j, _ := n.ToJson(d); j != `{"doc":{"a":{"$t":"x","@id":"1"},"b":{"$t":"y z","@k":"v","c":""},"e":{"$t":"h","@f":"g"}}}` {
+		t.Fatal(j)
+	}
+	// a sub-tree that's removed keeps them
+	n.RemoveChild(e)
+	e.SetAttr("i", "j")
+	if a := e.Attrs(); a[1].key != "@i" {
+		t.Fatal(a[1].key)
+	}
+}
@@ -0,0 +1,106 @@
+package x2j
+
+import (
+	"bufio"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestXmlBufferNext(t *testing.T) {
+	msgs := "<msg>1</msg>\n<msg>2</msg>\n<msg>3</msg>\n<msg>4</msg>\n"
+	for _, buf := range []*XmlBuffer{
+		NewXmlBuffer(msgs),
+		BytesNewXmlBuffer([]byte(msgs)),
+		ReaderNewXmlBuffer(bufio.NewReader(strings.NewReader(msgs))),
+	} {
+		m, err := buf.NextMap(true)
+		if err != nil || m["msg"] != float64(1) {
+			t.Fatal(m, err)
+		}
+		s, err := buf.NextJson()
+		if err != nil || s != `{"msg":"2"}` {
+			t.Fatal(s, err)
+		}
+		n, err := buf.NextTree()
+		if err != nil || n.key != "msg" || n.val != "3" {
+			t.Fatal(n, err)
+		}
+		b, err := buf.NextRaw()
+		if err != nil || string(b) != "<msg>4</msg>" {
+			t.Fatal(string(b), err)
+		}
+		if _, err = buf.NextMap(); err != io.EOF {
+			t.Fatal(err)
+		}
+		if err = buf.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = buf.NextRaw(); err == nil || err == io.EOF {
+			t.Fatal(err)
+		}
+	}
+	var _ io.Closer = NewXmlBuffer("")
+
+	// the Decoder settings apply
+	d := NewDecoder()
+	d.Recast = true
+	d.TextKey = "$t"
+	d.Types = map[string]TypeHint{"n": {Type: TypeInt64}}
+	for _, buf := range []*XmlBuffer{
+		d.NewXmlBuffer(`<msg id="x"><n>1</n>2</msg><msg>3</msg>`),
+		d.BytesNewXmlBuffer([]byte(`<msg id="x"><n>1</n>2</msg><msg>3</msg>`)),
+		d.ReaderNewXmlBuffer(strings.NewReader(`<msg id="x"><n>1</n>2</msg><msg>3</msg>`)),
+	} {
+		m, err := buf.NextMap()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mm := m["msg"].(map[string]interface{}); mm["$t"] != float64(2) || mm["n"] != int64(1) {
+			t.Fatal(m)
+		}
+		if m, err = buf.NextMap(false); err != nil || m["msg"] != "3" {
+			t.Fatal(m, err)
+		}
+	}
+}
+
+func TestXmlBufferConcurrent(t *testing.T) {
+	const n = 200
+	buf := NewXmlBuffer(pipeMsgs(n))
+	defer buf.Close()
+	var mu sync.Mutex
+	var got []int
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				m, err := buf.NextMap(true)
+				if err == io.EOF {
+					return
+				}
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mu.Lock()
+				got = append(got, msgSeq(m))
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	sort.Ints(got)
+	want := make([]int, n)
+	for i := range want {
+		want[i] = i
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("%d: %v", len(got), got)
+	}
+}
@@ -7,94 +7,80 @@
 package x2j
 
 import (
+	"bufio"
 	"bytes"
-	"encoding/xml"
+	"context"
 	"errors"
 	"io"
-	"os"
-	"regexp"
+	"strings"
 	"sync"
 )
 
 // XmlMsgsFromFile()
-//	'fname' is name of file
+//	'fname' is name of file; if it's compressed with gzip or bzip2, it's decompressed as it's read
 //	'phandler' is the map processing handler. Return of 'false' stops further processing.
 //	'ehandler' is the parsing error handler. Return of 'false' stops further processing and returns error.
 //	Note: phandler() and ehandler() calls are blocking, so reading and processing of messages is serialized.
 //	      This means that you can stop reading the file on error or after processing a particular message.
 //	      To have reading and handling run concurrently, pass arguments to a go routine in handler and return true.
+//	      Or set the Decoder Pipeline to decode messages concurrently and handle them in order.
+//	      After a malformed message, the error is a *BadMsgError and reading resumes at the next message.
 func XmlMsgsFromFile(fname string, phandler func(map[string]interface{})(bool), ehandler func(error)(bool), recast ...bool) error {
-	var r bool
-	if len(recast) == 1 {
-		r = recast[0]
-	}
-	fi, fierr := os.Stat(fname)
-	if fierr != nil {
-		return fierr
-	}
-	fh, fherr := os.Open(fname)
-	if fherr != nil {
-		return fherr
+	return defaultDecoder(recast).XmlMsgsFromFile(fname, phandler, ehandler)
+}
+
+// (*Decoder)XmlMsgsFromFile() - XmlMsgsFromFile using the Decoder settings.
+func (d *Decoder) XmlMsgsFromFile(fname string, phandler func(map[string]interface{})(bool), ehandler func(error)(bool)) error {
+	return d.xmlMsgsFromFile(context.Background(), fname, phandler, ehandler)
+}
+
+// (*Decoder)xmlMsgsFromFile() - XmlMsgsFromFile, checking 'ctx' between tokens and messages.
+func (d *Decoder) xmlMsgsFromFile(ctx context.Context, fname string, phandler func(map[string]interface{})(bool), ehandler func(error)(bool)) error {
+	fh, err := openMsgsFile(fname, 0)
+	if err != nil {
+		return err
 	}
 	defer fh.Close()
-	buf := make([]byte,fi.Size())
-	_, rerr  :=  fh.Read(buf)
-	if rerr != nil {
-		return rerr
-	}
-	doc := string(buf)
-
-	// xml.Decoder doesn't properly handle whitespace in some doc
-	// see songTextString.xml test case ... 
-	reg,_ := regexp.Compile("[ \t\n\r]*<")
-	doc = reg.ReplaceAllString(doc,"<")
-	b := bytes.NewBufferString(doc)
-
-	for {
-		m, merr := XmlBufferToMap(b,r)
-		if merr != nil && merr != io.EOF {
-			if ok := ehandler(merr); !ok {
-				// caused reader termination
-				return merr
-			 }
-		}
-		if m != nil {
-			if ok := phandler(m); !ok {
-				break
-			}
-		}
-		if merr == io.EOF {
-			break
-		}
-	}
-	return nil
+	return d.xmlMsgsFromReader(ctx, fh, phandler, ehandler)
 }
 
 // XmlBufferToMap - process XML message from a bytes.Buffer
 //	'b' is the buffer
 //	Optional argument 'recast' coerces map values to float64 or bool where possible.
 func XmlBufferToMap(b *bytes.Buffer,recast ...bool) (map[string]interface{},error) {
-	var r bool
-	if len(recast) == 1 {
-		r = recast[0]
-	}
+	return defaultDecoder(recast).XmlBufferToMap(b)
+}
+
+// (*Decoder)XmlBufferToMap - process XML message from a bytes.Buffer using the Decoder settings.
+func (d *Decoder) XmlBufferToMap(b *bytes.Buffer) (map[string]interface{}, error) {
+	return d.xmlBufferToMap(context.Background(), b)
+}
 
-	n,err := XmlBufferToTree(b)
+// (*Decoder)xmlBufferToMap - XmlBufferToMap, checking 'ctx' between tokens.
+func (d *Decoder) xmlBufferToMap(ctx context.Context, b *bytes.Buffer) (map[string]interface{}, error) {
+	n, err := d.xmlBufferToTree(ctx, b)
 	if err != nil {
 		return nil, err
 	}
 
-	m := make(map[string]interface{})
-	m[n.key] = n.treeToMap(r)
-
-	return m,nil
+	return n.ToMap(d), nil
 }
 
 // BufferToTree - derived from DocToTree()
 func XmlBufferToTree(b *bytes.Buffer) (*Node, error) {
-	p := xml.NewDecoder(b)
-	p.CharsetReader = X2jCharsetReader
-	n, berr := xmlToTree("",nil,p)
+	return defaultDecoder(nil).XmlBufferToTree(b)
+}
+
+// (*Decoder)XmlBufferToTree - XmlBufferToTree using the Decoder settings.
+func (d *Decoder) XmlBufferToTree(b *bytes.Buffer) (*Node, error) {
+	return d.xmlBufferToTree(context.Background(), b)
+}
+
+// (*Decoder)xmlBufferToTree - XmlBufferToTree, checking 'ctx' between tokens.
+func (d *Decoder) xmlBufferToTree(ctx context.Context, b *bytes.Buffer) (*Node, error) {
+	p := d.newParser(b)
+	p.withContext(ctx)
+	n, berr := d.xmlToTree(p)
 	if berr != nil {
 		return nil, berr
 	}
@@ -103,66 +89,115 @@ func XmlBufferToTree(b *bytes.Buffer) (*Node, error) {
 }
 
 // XmlBuffer - create XML decoder buffer for a string from anywhere, not necessarily a file.
+// Messages are read as for XmlMsgsFromReader(). It's safe for concurrent use; each message is
+// returned once. It can be left for garbage collection; Close() just ends reading from it.
 type XmlBuffer struct {
-	cnt uint64
-	str *string
-	buf *bytes.Buffer
+	mtx sync.Mutex
+	spl *MsgSplitter // nil once closed; its Decoder decodes the messages
 }
-var mtx sync.Mutex
-var cnt uint64
-var activeXmlBufs = make(map[uint64]*XmlBuffer)
 
-// NewXmlBuffer() - creates a bytes.Buffer from a string with multiple messages
-//	Use Close() function to release the buffer for garbage collection.
+var errBufferClosed = errors.New("Buffer is not active.")
+
+// NewXmlBuffer() - creates a buffer from a string with multiple messages
 func NewXmlBuffer(s string) *XmlBuffer {
-	// xml.Decoder doesn't properly handle whitespace in some doc
-	// see songTextString.xml test case ... 
-	reg,_ := regexp.Compile("[ \t\n\r]*<")
-	s = reg.ReplaceAllString(s,"<")
-	b := bytes.NewBufferString(s)
-	buf := new(XmlBuffer)
-	buf.str = &s
-	buf.buf = b
-	mtx.Lock()
-	defer mtx.Unlock()
-	buf.cnt = cnt ; cnt++
-	activeXmlBufs[buf.cnt] = buf
-	return buf
-}
-
-// BytesNewXmlBuffer() - creates a bytes.Buffer from b with possibly multiple messages
-//	Use Close() function to release the buffer for garbage collection.
+	return defaultDecoder(nil).NewXmlBuffer(s)
+}
+
+// (*Decoder)NewXmlBuffer() - NewXmlBuffer decoding messages using the Decoder settings.
+func (d *Decoder) NewXmlBuffer(s string) *XmlBuffer {
+	return d.ReaderNewXmlBuffer(strings.NewReader(s))
+}
+
+// BytesNewXmlBuffer() - creates a buffer from b with possibly multiple messages
 func BytesNewXmlBuffer(b []byte) *XmlBuffer {
-	bb := bytes.NewBuffer(b)
-	buf := new(XmlBuffer)
-	buf.buf = bb
-	mtx.Lock()
-	defer mtx.Unlock()
-	buf.cnt = cnt ; cnt++
-	activeXmlBufs[buf.cnt] = buf
-	return buf
+	return defaultDecoder(nil).BytesNewXmlBuffer(b)
+}
+
+// (*Decoder)BytesNewXmlBuffer() - BytesNewXmlBuffer decoding messages using the Decoder settings.
+func (d *Decoder) BytesNewXmlBuffer(b []byte) *XmlBuffer {
+	return d.ReaderNewXmlBuffer(bytes.NewReader(b))
+}
+
+// ReaderNewXmlBuffer() - creates a buffer that reads messages from rdr as they're retrieved.
+//	If rdr is an io.ByteReader, it's read no further than the last message retrieved. It isn't
+//	closed by Close().
+func ReaderNewXmlBuffer(rdr io.Reader) *XmlBuffer {
+	return defaultDecoder(nil).ReaderNewXmlBuffer(rdr)
+}
+
+// (*Decoder)ReaderNewXmlBuffer() - ReaderNewXmlBuffer decoding messages using the Decoder settings.
+// The optional 'recast' argument of NextMap() and NextJson() overrides the Decoder Recast setting.
+func (d *Decoder) ReaderNewXmlBuffer(rdr io.Reader) *XmlBuffer {
+	return &XmlBuffer{spl: d.NewMsgSplitter(rdr)}
+}
+
+// Close() - end reading from the buffer; the Next methods then return an error.
+// It's an io.Closer.
+func (buf *XmlBuffer) Close() error {
+	buf.mtx.Lock()
+	defer buf.mtx.Unlock()
+	buf.spl = nil
+	return nil
 }
 
-// Close() - release the buffer address for garbage collection
-func (buf *XmlBuffer)Close() {
-	mtx.Lock()
-	defer mtx.Unlock()
-	delete(activeXmlBufs,buf.cnt)
+// (*XmlBuffer)next - the next message, decoded to a tree and, if 'toMap', a map.
+func (buf *XmlBuffer) next(recast []bool, toMap bool) (*Node, *XmlMsg, error) {
+	buf.mtx.Lock()
+	defer buf.mtx.Unlock()
+	if buf.spl == nil {
+		return nil, nil, errBufferClosed
+	}
+	d := buf.spl.d
+	if len(recast) == 1 && recast[0] != d.Recast {
+		dd := *d
+		dd.Recast = recast[0]
+		d = &dd
+	}
+	if toMap {
+		msg, err := buf.spl.next(d)
+		return nil, msg, err
+	}
+	return buf.spl.nextTree(d)
 }
 
 // NextMap() - retrieve next XML message in buffer as a map[string]interface{} value.
 //	The optional argument 'recast' will try and coerce values to float64 or bool as appropriate.
-func (buf *XmlBuffer)NextMap(recast ...bool) (map[string]interface{}, error) {
-		var r bool
-		if len(recast) == 1 {
-			r = recast[0]
-		}
-		if _, ok := activeXmlBufs[buf.cnt]; !ok {
-			return nil, errors.New("Buffer is not active.")
-		}
-		return XmlBufferToMap(buf.buf,r)
+//	When there are no more messages, the error is io.EOF; after a malformed message, it's
+//	a *BadMsgError and the next message is read from the next plausible start of a message.
+func (buf *XmlBuffer) NextMap(recast ...bool) (map[string]interface{}, error) {
+	_, msg, err := buf.next(recast, true)
+	if err != nil {
+		return nil, err
+	}
+	return msg.Map, nil
+}
+
+// NextJson() - retrieve next XML message in buffer as a JSON string. See NextMap().
+func (buf *XmlBuffer) NextJson(recast ...bool) (string, error) {
+	_, msg, err := buf.next(recast, true)
+	if err != nil {
+		return "", err
+	}
+	s, err := jsonValue(msg.Map)
+	return s.(string), err
 }
 
+// NextTree() - retrieve next XML message in buffer as a tree. See NextMap().
+func (buf *XmlBuffer) NextTree() (*Node, error) {
+	n, _, err := buf.next(nil, false)
+	return n, err
+}
+
+// NextRaw() - retrieve the raw bytes of the next XML message in buffer - any prolog through
+// the end tag of its root element - without decoding it. See NextMap().
+func (buf *XmlBuffer) NextRaw() ([]byte, error) {
+	buf.mtx.Lock()
+	defer buf.mtx.Unlock()
+	if buf.spl == nil {
+		return nil, errBufferClosed
+	}
+	return buf.spl.NextRaw()
+}
 
 // =============================  io.Reader version for stream processing  ======================
 
@@ -173,29 +208,76 @@ func (buf *XmlBuffer)NextMap(recast ...bool) (map[string]interface{}, error) {
 //	Note: phandler() and ehandler() calls are blocking, so reading and processing of messages is serialized.
 //	      This means that you can stop reading the file on error or after processing a particular message.
 //	      To have reading and handling run concurrently, pass arguments to a go routine in handler and return true.
+//	      Or set the Decoder Pipeline to decode messages concurrently and handle them in order.
+//	      After a malformed message, the error is a *BadMsgError and reading resumes at the next message.
 func XmlMsgsFromReader(rdr io.Reader, phandler func(map[string]interface{})(bool), ehandler func(error)(bool), recast ...bool) error {
-	var r bool
-	if len(recast) == 1 {
-		r = recast[0]
+	return defaultDecoder(recast).XmlMsgsFromReader(rdr, phandler, ehandler)
+}
+
+// (*Decoder)XmlMsgsFromReader() - XmlMsgsFromReader using the Decoder settings.
+func (d *Decoder) XmlMsgsFromReader(rdr io.Reader, phandler func(map[string]interface{})(bool), ehandler func(error)(bool)) error {
+	return d.xmlMsgsFromReader(context.Background(), rdr, phandler, ehandler)
+}
+
+// (*Decoder)xmlMsgsFromReader() - XmlMsgsFromReader, checking 'ctx' between tokens and messages.
+func (d *Decoder) xmlMsgsFromReader(ctx context.Context, rdr io.Reader, phandler func(map[string]interface{})(bool), ehandler func(error)(bool)) error {
+	return d.readMsgs(d.bulkSplitter(ctx, rdr, 0, 0), mapValue, mapHandler(phandler, ehandler))
+}
+
+// (*Decoder)bulkSplitter - the MsgSplitter the bulk readers read 'rdr' with;
+// 'rdr' starts at input offset 'off' and with message 'seq'.
+func (d *Decoder) bulkSplitter(ctx context.Context, rdr io.Reader, off int64, seq int) *MsgSplitter {
+	if _, ok := rdr.(io.ByteReader); !ok && d.Pipeline.Workers > 0 {
+		// it's read ahead anyway
+		rdr = bufio.NewReader(rdr)
 	}
+	s := d.newMsgSplitter(ctx, rdr)
+	s.r.pos.Offset, s.seq = off, seq
+	return s
+}
 
-	for {
-		m, merr := ToMap(rdr,r)
-		if merr != nil && merr != io.EOF {
-			if ok := ehandler(merr); !ok {
-				// caused reader termination
-				return merr
-			 }
+// (*Decoder)readMsgs - read the messages from 's', convert their maps with 'conv' and pass them to
+// 'handle', which returns false to stop processing, along with the error to return.
+// If d.Pipeline.Workers > 0, they're decoded concurrently; see pipeline().
+func (d *Decoder) readMsgs(s *MsgSplitter, conv func(map[string]interface{}) (interface{}, error),
+	handle func(msg *XmlMsg, v interface{}, err error) (bool, error)) error {
+	if d.Pipeline.Workers > 0 {
+		return d.pipeline(s, conv, handle)
+	}
+	for msgs := 0; ; msgs++ {
+		if err := contextError(s.ctx, nil, msgs); err != nil {
+			return err
 		}
-		if m != nil {
-			if ok := phandler(m); !ok {
-				break
-			}
+		var v interface{}
+		msg, merr := s.Next()
+		if merr == nil {
+			v, merr = conv(msg.Map)
 		}
 		if merr == io.EOF {
-			break
+			return nil
+		}
+		if err := contextError(s.ctx, merr, msgs); err != nil {
+			return err
+		}
+		if ok, err := handle(msg, v, merr); !ok {
+			// caused reader termination?
+			return err
 		}
 	}
-	return nil
+}
+
+// mapValue - a 'conv' function for readMsgs(); the map as is.
+func mapValue(m map[string]interface{}) (interface{}, error) {
+	return m, nil
+}
+
+// mapHandler - a 'handle' function for readMsgs(), for the map bulk readers.
+func mapHandler(phandler func(map[string]interface{}) bool, ehandler func(error) bool) func(*XmlMsg, interface{}, error) (bool, error) {
+	return func(msg *XmlMsg, v interface{}, err error) (bool, error) {
+		if err != nil {
+			return ehandler(err), err
+		}
+		return phandler(v.(map[string]interface{})), nil
+	}
 }
 
@@ -7,69 +7,112 @@ package x2j
 import (
 	"bytes"
 	"encoding/xml"
-	"errors"
-	"io"
 	"strconv"
 	"strings"
 )
 
-// xmlToMap - convert a XML doc into map[string]interface{} value
-func xmlToMap(doc []byte, r bool) (map[string]interface{}, error) {
+// (*Decoder)xmlToMap - convert a XML doc into map[string]interface{} value
+func (d *Decoder) xmlToMap(doc []byte) (map[string]interface{}, error) {
 	b := bytes.NewReader(doc)
-	p := xml.NewDecoder(b)
-	p.CharsetReader = X2jCharsetReader
-	return xmlToMapParser("", nil, p, r)
+	if d.Positions {
+		n, err := d.xmlToTree(d.newParser(b))
+		if err != nil {
+			return nil, err
+		}
+		return n.ToMap(d), nil
+	}
+	p := d.newParser(b)
+	return d.xmlToMapParser(p)
 }
 
 // ===================================== where the work happens =============================
 
-// xmlToMapParser (2015.11.12) - load a 'clean' XML doc into a map[string]interface{} directly.
+// mapFrame - an element that's open in elementToMap(). The open elements are kept on a stack
+// rather than parsed by recursion, so the depth of the doc doesn't grow the goroutine stack;
+// treeToMap(), Walk(), WriteXml(), etc., walk maps and trees the same way.
+// NOTE: all attributes and sub-elements are parsed into 'na', 'na' is the value for 'skey'
+// unless 'skey' is a simple element w/o attributes, in which case the xml.CharData value is the value.
+type mapFrame struct {
+	skey     string
+	n, na    map[string]interface{} // n is the singleton map {skey: value}; na is old n.nodes
+	text     string                 // the text, joined for MixedConcat and MixedSequence
+	seq      []interface{}          // all the contents, in order, for MixedSequence
+	gap      bool                   // something other than text since the last of it; see joinText()
+	ws       string                 // whitespace-only value, for WhitespacePreserve
+	ns       *nsScope               // the namespace scope of the element; see NamespaceMode
+	preserve bool                   // in the scope of xml:space="preserve"; see textSep()
+}
+
+// (*Decoder)xmlToMapParser (2015.11.12) - load a 'clean' XML doc into a map[string]interface{} directly.
 // A refactoring of xmlToTreeParser(), markDuplicate() and treeToMap() - here, all-in-one.
 // We've removed the intermediate *node tree with the allocation and subsequent rescanning.
-func xmlToMapParser(skey string, a []xml.Attr, p *xml.Decoder, r bool) (map[string]interface{}, error) {
-	// NOTE: all attributes and sub-elements parsed into 'na', 'na' is returned as value for 'skey'
-	// Unless 'skey' is a simple element w/o attributes, in which case the xml.CharData value is the value.
-	var n, na map[string]interface{}
-
-	// Allocate maps and load attributes, if any.
-	if skey != "" {
-		n = make(map[string]interface{})  // old n
-		na = make(map[string]interface{}) // old n.nodes
-		if len(a) > 0 {
-			for _, v := range a {
-				na[`-` + v.Name.Local] = cast(v.Value, r)
+func (d *Decoder) xmlToMapParser(p *xmlParser) (map[string]interface{}, error) {
+	var pm map[string]interface{} // annotations ahead of the root element
+	for {
+		t, err := p.Token()
+		if err != nil {
+			return nil, p.parseError(err)
+		}
+		switch t.(type) {
+		case xml.StartElement:
+			tt := t.(xml.StartElement)
+			nns := d.pushScope(nil, tt.Attr)
+			m, err := d.elementToMap(p, d.nameKey(tt.Name, nns, false), tt.Attr, nns)
+			if err != nil {
+				return nil, err
+			}
+			for k, v := range pm {
+				m[k] = v
+			}
+			return m, nil
+		case xml.CharData:
+			// per Adrian (http://www.adrianlungu.com/) catch stray text
+			// in decoder stream -
+			// https://github.com/clbanning/mxj/pull/14#issuecomment-182816374
+			// NOTE: CharSetReader must be set to non-UTF-8 CharSet or you'll get
+			// a p.Token() decoding error when the BOM is UTF-16 or UTF-32.
+			continue
+		default:
+			if akey, aval, _, ok := d.annotation(t, p); ok {
+				if pm == nil {
+					pm = make(map[string]interface{})
+				}
+				addMapValue(pm, akey, aval)
 			}
 		}
 	}
+}
+
+// (*Decoder)elementToMap - load element 'skey', whose xml.StartElement has just been read,
+// into a singleton map[string]interface{}. 'ns' is the namespace scope of the element.
+func (d *Decoder) elementToMap(p *xmlParser, skey string, a []xml.Attr, ns *nsScope) (map[string]interface{}, error) {
+	var open []*mapFrame // the ancestors of 'f'
+	f, err := d.newMapFrame(p, skey, a, ns)
+	if err != nil {
+		return nil, p.parseError(err)
+	}
 	for {
 		t, err := p.Token()
 		if err != nil {
-			if err != io.EOF {
-				return nil, errors.New("xml.Decoder.Token() - " + err.Error())
-			}
-			return nil, err
+			return nil, p.parseError(err)
 		}
 		switch t.(type) {
 		case xml.StartElement:
 			tt := t.(xml.StartElement)
-
-			// First call to xmlToMapParser() doesn't pass xml.StartElement - the map key.
-			// So when the loop is first entered, the first token is the root tag along
-			// with any attributes, which we process here.
-			//
-			// Subsequent calls to xmlToMapParser() will pass in tag+attributes for
-			// processing before getting the next token which is the element value,
-			// which is done above.
-			if skey == "" {
-				return xmlToMapParser(tt.Name.Local, tt.Attr, p, r)
+			open = append(open, f)
+			nns := d.pushScope(f.ns, tt.Attr)
+			if f, err = d.newMapFrame(p, d.nameKey(tt.Name, nns, false), tt.Attr, nns); err != nil {
+				return nil, p.parseError(err)
 			}
-
-			// If not initializing the map, parse the element.
-			// len(nn) == 1, necessarily - it is just an 'n'.
-			nn, err := xmlToMapParser(tt.Name.Local, tt.Attr, p, r)
+		case xml.EndElement:
+			nn, err := d.endMapFrame(p, f)
 			if err != nil {
-				return nil, err
+				return nil, p.parseError(err)
 			}
+			if len(open) == 0 {
+				return nn, nil
+			}
+			f, open = open[len(open)-1], open[:len(open)-1]
 
 			// The nn map[string]interface{} value is a na[nn_key] value.
 			// We need to see if nn_key already exists - means we're parsing a list.
@@ -84,87 +127,162 @@ func xmlToMapParser(skey string, a []xml.Attr, p *xml.Decoder, r bool) (map[stri
 			// 'na' holding sub-elements of n.
 			// See if 'key' already exists.
 			// If 'key' exists, then this is a list, if not just add key:val to na.
-			if v, ok := na[key]; ok {
-				var a []interface{}
-				switch v.(type) {
-				case []interface{}:
-					a = v.([]interface{})
-				default: // anything else - note: v.(type) != nil
-					a = []interface{}{v}
-				}
-				a = append(a, val)
-				na[key] = a
-			} else {
-				na[key] = val // save it as a singleton
+			addListValue(f.na, key, val, p.forced(key))
+			if d.Mixed == MixedSequence {
+				f.seq = append(f.seq, nn)
 			}
-		case xml.EndElement:
-			// len(n) > 0 if this is a simple element w/o xml.Attrs - see xml.CharData case.
-			if len(n) == 0 {
-				// If len(na)==0 we have an empty element == "";
-				// it has no xml.Attr nor xml.CharData.
-				// Note: in original node-tree parser, val defaulted to "";
-				// so we always had the default if len(node.nodes) == 0.
-				if len(na) > 0 {
-					n[skey] = na
-				} else {
-					n[skey] = "" // empty element
+			f.gap = true
+		case xml.CharData:
+			if akey, aval, _, ok := d.annotation(t, p); ok {
+				d.addAnnotation(f.n, f.na, f.skey, akey, aval)
+				if d.Mixed == MixedSequence {
+					f.seq = append(f.seq, map[string]interface{}{akey: aval})
 				}
+				f.gap = true
+				continue
 			}
-			return n, nil
-		case xml.CharData:
 			// clean up possible noise
-			tt := strings.Trim(string(t.(xml.CharData)), "\t\r\b\n ")
-			if len(tt) > 0 {
-				if len(na) > 0 {
-					na["#text"] = cast(tt, r)
-				} else if skey != "" {
-					n[skey] = cast(tt, r)
-				} else {
-					// per Adrian (http://www.adrianlungu.com/) catch stray text
-					// in decoder stream -
-					// https://github.com/clbanning/mxj/pull/14#issuecomment-182816374
-					// NOTE: CharSetReader must be set to non-UTF-8 CharSet or you'll get
-					// a p.Token() decoding error when the BOM is UTF-16 or UTF-32.
-					continue
-				}
+			tt, ok := d.text(string(t.(xml.CharData)), p.preserve())
+			if !ok {
+				f.ws = tt
+				continue
+			}
+			if d.Mixed != MixedDefault {
+				// the joined text is decoded by mixedContent()
+				f.addText(d, tt)
+				continue
+			}
+			val, err := d.convert(p, "", tt)
+			if err != nil {
+				return nil, p.parseError(err)
+			}
+			if len(f.na) > 0 {
+				f.na[d.textKey()] = val
+			} else {
+				f.n[f.skey] = val
 			}
 		default:
-			// noop
+			if akey, aval, _, ok := d.annotation(t, p); ok {
+				d.addAnnotation(f.n, f.na, f.skey, akey, aval)
+				if d.Mixed == MixedSequence {
+					f.seq = append(f.seq, map[string]interface{}{akey: aval})
+				}
+			}
+			f.gap = true
 		}
 	}
 }
 
+// (*Decoder)newMapFrame - open element 'skey': allocate maps and load attributes, if any.
+func (d *Decoder) newMapFrame(p *xmlParser, skey string, a []xml.Attr, ns *nsScope) (*mapFrame, error) {
+	p.push(skey)
+	f := &mapFrame{skey: skey, ns: ns, preserve: p.preserve()}
+	f.n = make(map[string]interface{})  // old n
+	f.na = make(map[string]interface{}) // old n.nodes
+	for _, v := range a {
+		key := d.attrPrefix() + d.nameKey(v.Name, ns, true)
+		val, err := d.convert(p, key, v.Value)
+		if err != nil {
+			return nil, err
+		}
+		f.na[key] = val
+	}
+	return f, nil
+}
+
+// (*Decoder)endMapFrame - close element 'f' and return its singleton map.
+func (d *Decoder) endMapFrame(p *xmlParser, f *mapFrame) (map[string]interface{}, error) {
+	if f.text != "" {
+		if err := d.mixedContent(p, f); err != nil {
+			return nil, err
+		}
+	}
+	// len(n) > 0 if this is a simple element w/o xml.Attrs - see xml.CharData case.
+	if len(f.n) == 0 {
+		// If len(na)==0 we have an empty element == "";
+		// it has no xml.Attr nor xml.CharData.
+		// Note: in original node-tree parser, val defaulted to "";
+		// so we always had the default if len(node.nodes) == 0.
+		if len(f.na) > 0 {
+			f.n[f.skey] = f.na
+		} else {
+			// empty element - its value is "" or whitespace, decoded as the others
+			val, err := d.convert(p, "", f.ws)
+			if err != nil {
+				return nil, err
+			}
+			f.n[f.skey] = val
+		}
+	}
+	p.pop()
+	return f.n, nil
+}
+
+// addListValue - addMapValue for the value of sub-element 'key'; if the element is 'forced'
+// to be a list - see Decoder ForceList - its first value is a list of one.
+func addListValue(m map[string]interface{}, key string, val interface{}, forced bool) {
+	if _, ok := m[key]; !ok && forced {
+		val = []interface{}{val}
+	}
+	addMapValue(m, key, val)
+}
+
+// addMapValue - set m[key] = val; if 'key' is already in 'm', its value becomes a list.
+func addMapValue(m map[string]interface{}, key string, val interface{}) {
+	if v, ok := m[key]; ok {
+		var a []interface{}
+		switch v.(type) {
+		case []interface{}:
+			a = v.([]interface{})
+		default: // anything else - note: v.(type) != nil
+			a = []interface{}{v}
+		}
+		m[key] = append(a, val)
+	} else {
+		m[key] = val // save it as a singleton
+	}
+}
+
 var castNanInf bool
 
 // Cast "Nan", "Inf", "-Inf" XML values to 'float64'.
 // By default, these values will be decoded as 'string'.
+//
+//	Note: this sets the default for the package-level functions; use Decoder.CastNanInf
+//	to change it for a single Decoder.
 func CastNanInf(b bool) {
 	castNanInf = b
 }
 
-// cast - try to cast string values to bool or float64
-func cast(s string, r bool) interface{} {
-	if r {
-		// handle nan and inf
-		if !castNanInf {
-			switch strings.ToLower(s) {
-			case "nan", "inf", "-inf":
-				return interface{}(s)
-			}
-		}
+// (*Decoder)cast - try to cast string values to bool or float64 if the Decoder Recast setting is on.
+// Maps and trees are both decoded using it.
+func (d *Decoder) cast(s string) interface{} {
+	if !d.Recast {
+		return interface{}(s)
+	}
+	return cast(s, d.CastNanInf)
+}
 
-		// handle numeric strings ahead of boolean
-		if f, err := strconv.ParseFloat(s, 64); err == nil {
-			return interface{}(f)
+// cast - try to cast string values to bool or float64; "NaN", "Inf" and "-Inf" only if 'nanInf'.
+func cast(s string, nanInf bool) interface{} {
+	// handle nan and inf
+	if !nanInf {
+		switch strings.ToLower(s) {
+		case "nan", "inf", "-inf":
+			return interface{}(s)
 		}
-		// ParseBool treats "1"==true & "0"==false
-		// but be more strick - only allow TRUE, True, true, FALSE, False, false
-		if s != "t" && s != "T" && s != "f" && s != "F" {
-			if b, err := strconv.ParseBool(s); err == nil {
-				return interface{}(b)
-			}
+	}
+
+	// handle numeric strings ahead of boolean
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return interface{}(f)
+	}
+	// ParseBool treats "1"==true & "0"==false
+	// but be more strick - only allow TRUE, True, true, FALSE, False, false
+	if s != "t" && s != "T" && s != "f" && s != "F" {
+		if b, err := strconv.ParseBool(s); err == nil {
+			return interface{}(b)
 		}
 	}
 	return interface{}(s)
 }
-